@@ -0,0 +1,128 @@
+package zb
+
+import (
+	"sort"
+	"strings"
+)
+
+func (a Asset) Total() float64 {
+	return a.Available + a.Freeze
+}
+
+// Asset looks up the asset of coin by its key, e.g. "btc", ignoring case.
+func (a Account) Asset(coin string) (Asset, bool) {
+	for _, asset := range a.Assets {
+		if strings.EqualFold(asset.Coin.Key, coin) {
+			return asset, true
+		}
+	}
+	return Asset{}, false
+}
+
+// Totals returns available plus frozen balance per lower case coin key.
+func (a Account) Totals() map[string]float64 {
+	totals := map[string]float64{}
+	for _, asset := range a.Assets {
+		totals[strings.ToLower(asset.Coin.Key)] += asset.Total()
+	}
+	return totals
+}
+
+type CoinValue struct {
+	Amount float64
+	Price  float64
+	Value  float64
+}
+
+type Valuation struct {
+	Quote string
+	Total float64
+	Coins map[string]CoinValue
+	// Unpriced coins have a balance but no market against the quote coin
+	Unpriced []string
+}
+
+// ValueAccount prices every coin held in quote, e.g. "usdt", using the last price of
+// its <coin>_<quote> market. Coins without such a market in GetSymbols are Unpriced,
+// any error requesting a price fails the valuation. Each market is requested once.
+func ValueAccount(market MarketData, account Account, quote string) (Valuation, error) {
+	quote = strings.ToLower(quote)
+	valuation := Valuation{Quote: quote, Coins: map[string]CoinValue{}}
+	symbols, err := market.GetSymbols()
+	if err != nil {
+		return Valuation{}, err
+	}
+
+	totals := account.Totals()
+	var coins []string
+	for coin := range totals {
+		coins = append(coins, coin)
+	}
+	sort.Strings(coins)
+
+	for _, coin := range coins {
+		amount := totals[coin]
+		if amount == 0 {
+			continue
+		}
+
+		price := 1.0
+		if coin != quote {
+			symbol := coin + "_" + quote
+			if _, ok := symbols[symbol]; !ok {
+				valuation.Unpriced = append(valuation.Unpriced, coin)
+				continue
+			}
+			q, err := market.GetLatestQuote(symbol)
+			if err != nil {
+				return Valuation{}, err
+			}
+			price = q.Last
+		}
+
+		value := CoinValue{Amount: amount, Price: price, Value: amount * price}
+		valuation.Coins[coin] = value
+		valuation.Total += value.Value
+	}
+	return valuation, nil
+}
+
+type BalanceChange struct {
+	Coin      string
+	Available float64
+	Freeze    float64
+	Before    Asset
+	After     Asset
+}
+
+func (c BalanceChange) Total() float64 {
+	return c.Available + c.Freeze
+}
+
+// DiffAccounts reports every coin whose available or frozen balance differs between
+// two snapshots, ordered by coin.
+func DiffAccounts(before, after Account) []BalanceChange {
+	assets := map[string][2]Asset{}
+	for _, asset := range before.Assets {
+		pair := assets[strings.ToLower(asset.Coin.Key)]
+		pair[0] = asset
+		assets[strings.ToLower(asset.Coin.Key)] = pair
+	}
+	for _, asset := range after.Assets {
+		pair := assets[strings.ToLower(asset.Coin.Key)]
+		pair[1] = asset
+		assets[strings.ToLower(asset.Coin.Key)] = pair
+	}
+
+	var changes []BalanceChange
+	for coin, pair := range assets {
+		available := pair[1].Available - pair[0].Available
+		freeze := pair[1].Freeze - pair[0].Freeze
+		if available == 0 && freeze == 0 {
+			continue
+		}
+		changes = append(changes, BalanceChange{Coin: coin, Available: available, Freeze: freeze, Before: pair[0], After: pair[1]})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Coin < changes[j].Coin })
+	return changes
+}
@@ -0,0 +1,60 @@
+package zb_test
+
+import (
+	"github.com/berryland/zb"
+	"github.com/berryland/zb/zbtest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var snapshot = zb.Account{Assets: []zb.Asset{
+	{Available: 0.5, Freeze: 0.25, Coin: zb.Coin{Key: "btc"}},
+	{Available: 2, Coin: zb.Coin{Key: "eth"}},
+	{Available: 100, Freeze: 50, Coin: zb.Coin{Key: "usdt"}},
+	{Available: 10, Coin: zb.Coin{Key: "ltc"}},
+}}
+
+func TestAccount_Asset(t *testing.T) {
+	asset, ok := snapshot.Asset("BTC")
+	assert.True(t, ok)
+	assert.Equal(t, 0.75, asset.Total())
+
+	_, ok = snapshot.Asset("qc")
+	assert.False(t, ok)
+	assert.Equal(t, 150.0, snapshot.Totals()["usdt"])
+}
+
+func TestValueAccount(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+
+	valuation, err := zb.ValueAccount(s.RestClient(), snapshot, "USDT")
+	assert.Nil(t, err)
+	assert.Equal(t, zb.CoinValue{Amount: 0.75, Price: 11000, Value: 8250}, valuation.Coins["btc"])
+	assert.Equal(t, 8250+2000+150.0, valuation.Total)
+	assert.Equal(t, []string{"ltc"}, valuation.Unpriced)
+	assert.Equal(t, 2, s.Requests("ticker"))
+
+	s.InjectError("ticker", zb.TooFrequent)
+	_, err = zb.ValueAccount(s.RestClient(), snapshot, "usdt")
+	assert.NotNil(t, err)
+}
+
+func TestDiffAccounts(t *testing.T) {
+	after := zb.Account{Assets: []zb.Asset{
+		{Available: 0.75, Coin: zb.Coin{Key: "btc"}},
+		{Available: 2, Coin: zb.Coin{Key: "eth"}},
+		{Available: 100, Freeze: 50, Coin: zb.Coin{Key: "usdt"}},
+		{Available: 1, Coin: zb.Coin{Key: "qc"}},
+	}}
+
+	changes := zb.DiffAccounts(snapshot, after)
+	assert.Len(t, changes, 3)
+	assert.Equal(t, "btc", changes[0].Coin)
+	assert.Equal(t, 0.25, changes[0].Available)
+	assert.Equal(t, -0.25, changes[0].Freeze)
+	assert.Equal(t, 0.0, changes[0].Total())
+	assert.Equal(t, "ltc", changes[1].Coin)
+	assert.Equal(t, -10.0, changes[1].Total())
+	assert.Equal(t, "qc", changes[2].Coin)
+}
@@ -0,0 +1,490 @@
+// Package algo works large orders into the market over time with child limit orders:
+// TWAP and VWAP slice an order over a time window, Iceberg shows a small part of it at
+// a time. Every algo can be paused, resumed and cancelled while it runs and ends with a
+// Report comparing the average fill price to the arrival price.
+package algo
+
+import (
+	"context"
+	"errors"
+	"github.com/berryland/zb"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPollInterval = time.Second
+	maxCancelAttempts   = 10
+	epsilon             = 1e-9
+	// reconcileOrders is how many of the latest orders are searched for a lost child
+	// order, and reconcileWindow how much earlier than sent, in milliseconds, zb may
+	// have stamped it
+	reconcileOrders = 10
+	reconcileWindow = 60000
+)
+
+type Status uint8
+
+const (
+	Running Status = iota
+	Paused
+	Done
+	Cancelled
+	Failed
+)
+
+type Progress struct {
+	Status Status
+	Target float64
+	Filled float64
+	// Average fill price, 0 until something filled
+	Average float64
+	// Scheduled is how much the algo aims to have filled by now
+	Scheduled float64
+	Orders    int
+}
+
+type Report struct {
+	// Request is the executed request, its amount rounded down to the amount scale
+	Request zb.OrderRequest
+	Status  Status
+	Filled  float64
+	Average float64
+	// ArrivalPrice is the mid price when the algo started
+	ArrivalPrice float64
+	// SlippageBps is how much worse than ArrivalPrice the average fill was, in basis points
+	SlippageBps float64
+	Orders      []zb.Order
+	Start       time.Time
+	End         time.Time
+	// Err is why the algo failed
+	Err error
+}
+
+// Book keeps the latest depth of the symbols it follows, so algos price child orders
+// from the websocket instead of requesting depth every time.
+type Book struct {
+	mu     sync.Mutex
+	depths map[string]zb.Depth
+}
+
+func NewBook() *Book {
+	return &Book{depths: map[string]zb.Depth{}}
+}
+
+func (b *Book) Follow(ws zb.Streamer, symbol string) {
+	ws.SubscribeDepth(symbol, func(depth zb.Depth) {
+		b.Update(symbol, depth)
+	})
+}
+
+func (b *Book) Update(symbol string, depth zb.Depth) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.depths[symbol] = depth
+}
+
+func (b *Book) Depth(symbol string) (zb.Depth, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	depth, ok := b.depths[symbol]
+	return depth, ok
+}
+
+// Executor runs algos on trading. Depth comes from book when it has the symbol, from
+// market otherwise; book may be nil.
+type Executor struct {
+	trading   zb.Trading
+	market    zb.MarketData
+	book      *Book
+	accessKey string
+	secretKey string
+}
+
+func NewExecutor(trading zb.Trading, market zb.MarketData, book *Book, accessKey, secretKey string) *Executor {
+	return &Executor{trading: trading, market: market, book: book, accessKey: accessKey, secretKey: secretKey}
+}
+
+func (x *Executor) depth(symbol string) (zb.Depth, error) {
+	if x.book != nil {
+		if depth, ok := x.book.Depth(symbol); ok {
+			return depth, nil
+		}
+	}
+	return x.market.GetDepth(symbol, 10)
+}
+
+// bestPrices returns the lowest ask and the highest bid, 0 when a side is empty.
+func bestPrices(depth zb.Depth) (float64, float64) {
+	ask, bid := 0.0, 0.0
+	for _, e := range depth.Asks {
+		if ask == 0 || e.Price < ask {
+			ask = e.Price
+		}
+	}
+	for _, e := range depth.Bids {
+		if e.Price > bid {
+			bid = e.Price
+		}
+	}
+	return ask, bid
+}
+
+// Execution is a running algo.
+type Execution struct {
+	x       *Executor
+	request zb.OrderRequest
+	config  zb.SymbolConfig
+	arrival float64
+	start   time.Time
+	poll    time.Duration
+
+	mu        sync.Mutex
+	status    Status
+	cancelled bool
+	scheduled float64
+	orders    map[uint64]zb.Order
+	working   uint64
+	wake      chan struct{}
+	done      chan struct{}
+	report    Report
+	// unknown is the child order sent last when its response was lost
+	unknown *zb.Order
+}
+
+// start validates request, rounds its amount down to the amount scale, notes the arrival
+// price and runs algo in the background.
+func (x *Executor) start(ctx context.Context, request zb.OrderRequest, poll time.Duration, algo func(ctx context.Context, e *Execution) error) (*Execution, error) {
+	if request.TradeType != zb.Buy && request.TradeType != zb.Sell {
+		return nil, &zb.ApiError{Code: zb.InvalidArgument, Message: "Trade type must be buy or sell"}
+	}
+	if request.Amount <= 0 {
+		return nil, &zb.ApiError{Code: zb.InvalidAmount, Message: "Amount must be positive"}
+	}
+	symbols, err := x.market.GetSymbols()
+	if err != nil {
+		return nil, err
+	}
+	config, ok := symbols[request.Symbol]
+	if !ok {
+		return nil, &zb.ApiError{Code: zb.InvalidArgument, Message: "Unknown symbol: " + request.Symbol}
+	}
+	// a remainder below the amount scale could never be placed
+	request.Amount = roundDown(request.Amount, config.AmountScale)
+	if request.Amount <= 0 {
+		return nil, &zb.ApiError{Code: zb.InvalidAmount, Message: "Amount is below the amount scale of " + request.Symbol}
+	}
+	depth, err := x.depth(request.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	ask, bid := bestPrices(depth)
+	if ask == 0 || bid == 0 {
+		return nil, errors.New("No depth to price " + request.Symbol)
+	}
+	if poll <= 0 {
+		poll = defaultPollInterval
+	}
+
+	e := &Execution{
+		x:       x,
+		request: request,
+		config:  config,
+		arrival: (ask + bid) / 2,
+		start:   time.Now(),
+		poll:    poll,
+		orders:  map[uint64]zb.Order{},
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		err := algo(ctx, e)
+		if ctx.Err() != nil {
+			e.Cancel()
+		}
+		e.finish(err)
+	}()
+	return e, nil
+}
+
+func (e *Execution) Progress() Progress {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	filled, average := e.filled()
+	return Progress{Status: e.status, Target: e.request.Amount, Filled: filled, Average: average, Scheduled: e.scheduled, Orders: len(e.orders)}
+}
+
+// Pause cancels the working child order and places no more until Resume.
+func (e *Execution) Pause() {
+	e.setStatus(Paused)
+}
+
+func (e *Execution) Resume() {
+	e.setStatus(Running)
+}
+
+// Cancel stops the algo and cancels its working child order.
+func (e *Execution) Cancel() {
+	e.mu.Lock()
+	e.cancelled = true
+	e.mu.Unlock()
+	e.poke()
+}
+
+func (e *Execution) Done() <-chan struct{} {
+	return e.done
+}
+
+// Wait blocks until the algo has ended and returns its report.
+func (e *Execution) Wait() Report {
+	<-e.done
+	return e.report
+}
+
+func (e *Execution) setStatus(status Status) {
+	e.mu.Lock()
+	if e.status == Running || e.status == Paused {
+		e.status = status
+	}
+	e.mu.Unlock()
+	e.poke()
+}
+
+func (e *Execution) poke() {
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
+
+// state returns whether the algo should stop, and whether it is paused.
+func (e *Execution) state(ctx context.Context) (bool, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.cancelled || ctx.Err() != nil, e.status == Paused
+}
+
+// sleep waits for d or until Pause, Resume or Cancel is called.
+func (e *Execution) sleep(ctx context.Context, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-e.wake:
+	case <-timer.C:
+	}
+}
+
+func (e *Execution) setScheduled(amount float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.scheduled = amount
+}
+
+// filled sums the child orders. e.mu must be held.
+func (e *Execution) filled() (float64, float64) {
+	amount, money := 0.0, 0.0
+	for _, order := range e.orders {
+		amount += order.TradeAmount
+		if order.TradeMoney > 0 {
+			money += order.TradeMoney
+		} else {
+			money += order.Average * order.TradeAmount
+		}
+	}
+	if amount <= epsilon {
+		return 0, 0
+	}
+	return amount, money / amount
+}
+
+func (e *Execution) remaining() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	filled, _ := e.filled()
+	return e.request.Amount - filled
+}
+
+func (e *Execution) hasWorking() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.working != 0 || e.unknown != nil
+}
+
+// place sends a child order, rounded to the scales of the symbol. Amounts that round to
+// nothing are skipped. Only a refusal by zb is an error, rate limiting is retried later.
+// An order whose response was lost is looked for by the next sync.
+func (e *Execution) place(amount float64, price float64) error {
+	amount = roundDown(amount, e.config.AmountScale)
+	price = round(price, e.config.PriceScale)
+	if amount <= epsilon {
+		return nil
+	}
+
+	sent := zb.Order{Symbol: e.request.Symbol, Price: price, TotalAmount: amount, TradeType: e.request.TradeType, Status: zb.Pending, Time: millis(time.Now())}
+	id, err := e.x.trading.PlaceOrder(e.request.Symbol, price, amount, e.request.TradeType, e.x.accessKey, e.x.secretKey)
+	apiError, refused := err.(*zb.ApiError)
+	if refused && apiError.Code == zb.TooFrequent {
+		return nil
+	}
+	if refused && apiError.Code != zb.GeneralError {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err != nil {
+		e.unknown = &sent
+		return nil
+	}
+	sent.Id = id
+	e.orders[id] = sent
+	e.working = id
+	return nil
+}
+
+// sync fetches the working child order, errors are retried on the next poll.
+func (e *Execution) sync() {
+	e.mu.Lock()
+	id, unknown := e.working, e.unknown
+	e.mu.Unlock()
+	if unknown != nil {
+		e.reconcile(*unknown)
+		return
+	}
+	if id == 0 {
+		return
+	}
+
+	order, err := e.x.trading.GetOrder(e.request.Symbol, id, e.x.accessKey, e.x.secretKey)
+	if err != nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.orders[id] = order
+	if order.Status == zb.Finished || order.Status == zb.Cancelled {
+		e.working = 0
+	}
+}
+
+// reconcile looks for the child order sent lost among the latest orders of the symbol.
+// An order not found there was never placed.
+func (e *Execution) reconcile(sent zb.Order) {
+	orders, err := e.x.trading.GetOrders(e.request.Symbol, e.request.TradeType, 1, reconcileOrders, e.x.accessKey, e.x.secretKey)
+	if err != nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.unknown = nil
+	for _, order := range orders {
+		if _, known := e.orders[order.Id]; known || order.Time+reconcileWindow < sent.Time || order.TradeType != sent.TradeType ||
+			math.Abs(order.Price-sent.Price) > epsilon || math.Abs(order.TotalAmount-sent.TotalAmount) > epsilon {
+			continue
+		}
+		e.orders[order.Id] = order
+		if order.Status != zb.Finished && order.Status != zb.Cancelled {
+			e.working = order.Id
+		}
+		return
+	}
+}
+
+// cancelWorking cancels the working child order and waits for its final state. The
+// order stays working when that takes more than a few polls.
+func (e *Execution) cancelWorking() {
+	for attempt := 0; attempt < maxCancelAttempts && e.hasWorking(); attempt++ {
+		e.mu.Lock()
+		id := e.working
+		e.mu.Unlock()
+		if id == 0 {
+			// only a lost order, which has to be found before it can be cancelled
+			e.sync()
+			if e.hasWorking() {
+				time.Sleep(e.poll)
+			}
+			continue
+		}
+
+		err := e.x.trading.CancelOrder(e.request.Symbol, id, e.x.accessKey, e.x.secretKey)
+		if apiError, ok := err.(*zb.ApiError); ok && apiError.Code != zb.OrderNotFound && apiError.Code != zb.TooFrequent {
+			return
+		}
+		e.sync()
+		if e.hasWorking() {
+			time.Sleep(e.poll)
+		}
+	}
+}
+
+// takerPrice is the opposite best price, kept within the limit of the request.
+func (e *Execution) takerPrice() (float64, error) {
+	depth, err := e.x.depth(e.request.Symbol)
+	if err != nil {
+		return 0, err
+	}
+	ask, bid := bestPrices(depth)
+	limit := e.request.Price
+	if e.request.TradeType == zb.Buy {
+		if ask == 0 || limit > 0 && ask > limit {
+			return limit, nil
+		}
+		return ask, nil
+	}
+	if bid == 0 || limit > 0 && bid < limit {
+		return limit, nil
+	}
+	return bid, nil
+}
+
+func (e *Execution) finish(err error) {
+	e.cancelWorking()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch {
+	case err != nil:
+		e.status = Failed
+	case e.cancelled:
+		e.status = Cancelled
+	default:
+		e.status = Done
+	}
+
+	var orders []zb.Order
+	for _, order := range e.orders {
+		orders = append(orders, order)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].Id < orders[j].Id })
+
+	filled, average := e.filled()
+	e.report = Report{Request: e.request, Status: e.status, Filled: filled, Average: average, ArrivalPrice: e.arrival, Orders: orders, Start: e.start, End: time.Now(), Err: err}
+	if filled > 0 {
+		e.report.SlippageBps = (average - e.arrival) / e.arrival * 1e4
+		if e.request.TradeType == zb.Sell {
+			e.report.SlippageBps = -e.report.SlippageBps
+		}
+	}
+	close(e.done)
+}
+
+func round(value float64, scale byte) float64 {
+	pow := math.Pow(10, float64(scale))
+	return math.Floor(value*pow+0.5) / pow
+}
+
+func millis(t time.Time) uint64 {
+	return uint64(t.UnixNano() / int64(time.Millisecond))
+}
+
+func roundDown(value float64, scale byte) float64 {
+	pow := math.Pow(10, float64(scale))
+	return math.Floor(value*pow+epsilon) / pow
+}
@@ -0,0 +1,166 @@
+package algo
+
+import (
+	"context"
+	"github.com/berryland/zb"
+	"github.com/berryland/zb/zbtest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var depth = zb.Depth{Asks: []zb.DepthEntry{{Price: 11001, Volume: 10}}, Bids: []zb.DepthEntry{{Price: 10999, Volume: 10}}}
+
+func newExecutor(s *zbtest.Server, paper *zb.PaperClient) *Executor {
+	paper.OnDepth("btc_usdt", depth)
+	book := NewBook()
+	book.Update("btc_usdt", depth)
+	return NewExecutor(paper, s.RestClient(), book, "", "")
+}
+
+func TestExecutor_TWAP(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	paper := zb.NewPaperClient(map[string]float64{"usdt": 10000}, 0)
+	x := newExecutor(s, paper)
+
+	_, err := x.TWAP(context.Background(), zb.OrderRequest{Symbol: "btc_usdt", Amount: 0.4, TradeType: zb.Buy}, TWAPParams{})
+	assert.Equal(t, zb.InvalidArgument, err.(*zb.ApiError).Code)
+
+	e, err := x.TWAP(context.Background(), zb.OrderRequest{Symbol: "btc_usdt", Amount: 0.4, TradeType: zb.Buy}, TWAPParams{Duration: 200 * time.Millisecond, Slices: 4, PollInterval: 10 * time.Millisecond})
+	assert.Nil(t, err)
+
+	report := e.Wait()
+	assert.Equal(t, Done, report.Status)
+	assert.Len(t, report.Orders, 4)
+	assert.InDelta(t, 0.4, report.Filled, epsilon)
+	assert.InDelta(t, 11001, report.Average, epsilon)
+	assert.InDelta(t, 11000, report.ArrivalPrice, epsilon)
+	assert.InDelta(t, 1e4/11000, report.SlippageBps, epsilon)
+	assert.True(t, report.End.Sub(report.Start) >= 150*time.Millisecond)
+
+	progress := e.Progress()
+	assert.Equal(t, Done, progress.Status)
+	assert.InDelta(t, 0.4, progress.Scheduled, epsilon)
+}
+
+func TestExecutor_Iceberg(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	paper := zb.NewPaperClient(map[string]float64{"btc": 1}, 0)
+	x := newExecutor(s, paper)
+
+	e, err := x.Iceberg(context.Background(), zb.OrderRequest{Symbol: "btc_usdt", Price: 11010, Amount: 0.3, TradeType: zb.Sell}, IcebergParams{Visible: 0.1, PollInterval: 5 * time.Millisecond})
+	assert.Nil(t, err)
+	waitFor(t, func() bool { return e.Progress().Orders == 1 })
+
+	paper.OnTrades("btc_usdt", []zb.Trade{{Price: 11010, Amount: 0.5, TradeType: zb.Buy}})
+	waitFor(t, func() bool { return e.Progress().Orders == 2 })
+	open, _ := paper.GetOpenOrders("btc_usdt", "", "")
+	assert.Len(t, open, 1)
+	assert.InDelta(t, 0.1, open[0].TotalAmount, epsilon)
+
+	e.Pause()
+	waitFor(t, func() bool {
+		open, _ := paper.GetOpenOrders("btc_usdt", "", "")
+		return len(open) == 0
+	})
+	assert.Equal(t, Paused, e.Progress().Status)
+
+	e.Resume()
+	waitFor(t, func() bool { return e.Progress().Orders == 3 })
+
+	e.Cancel()
+	report := e.Wait()
+	assert.Equal(t, Cancelled, report.Status)
+	assert.InDelta(t, 0.1, report.Filled, epsilon)
+	assert.InDelta(t, 11010, report.Average, epsilon)
+	assert.InDelta(t, -10/11000.0*1e4, report.SlippageBps, epsilon)
+	open, _ = paper.GetOpenOrders("btc_usdt", "", "")
+	assert.Empty(t, open)
+}
+
+func TestExecutor_IcebergScale(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	paper := zb.NewPaperClient(map[string]float64{"btc": 1}, 0)
+	x := newExecutor(s, paper)
+
+	_, err := x.Iceberg(context.Background(), zb.OrderRequest{Symbol: "btc_usdt", Price: 11010, Amount: 0.00005, TradeType: zb.Sell}, IcebergParams{Visible: 0.1})
+	assert.Equal(t, zb.InvalidAmount, err.(*zb.ApiError).Code)
+
+	// the 0.00005 beyond the amount scale of btc_usdt is not waited for
+	e, err := x.Iceberg(context.Background(), zb.OrderRequest{Symbol: "btc_usdt", Price: 11010, Amount: 0.10005, TradeType: zb.Sell}, IcebergParams{Visible: 0.1, PollInterval: 5 * time.Millisecond})
+	assert.Nil(t, err)
+	waitFor(t, func() bool { return e.Progress().Orders == 1 })
+	paper.OnTrades("btc_usdt", []zb.Trade{{Price: 11010, Amount: 0.5, TradeType: zb.Buy}})
+
+	report := e.Wait()
+	assert.Equal(t, Done, report.Status)
+	assert.Len(t, report.Orders, 1)
+	assert.InDelta(t, 0.1, report.Filled, epsilon)
+	assert.InDelta(t, 0.1, report.Request.Amount, epsilon)
+}
+
+// lossyTrading places the next orders but loses their response, as an unreadable one.
+type lossyTrading struct {
+	*zb.PaperClient
+	lose int
+}
+
+func (t *lossyTrading) PlaceOrder(symbol string, price, amount float64, tradeType zb.TradeType, accessKey, secretKey string) (uint64, error) {
+	id, err := t.PaperClient.PlaceOrder(symbol, price, amount, tradeType, accessKey, secretKey)
+	if err == nil && t.lose > 0 {
+		t.lose--
+		return 0, &zb.ApiError{Code: zb.GeneralError, Message: "Malformed response"}
+	}
+	return id, err
+}
+
+func TestExecutor_LostResponse(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	paper := zb.NewPaperClient(map[string]float64{"btc": 1}, 0)
+	x := newExecutor(s, paper)
+	x.trading = &lossyTrading{PaperClient: paper, lose: 1}
+
+	// the first part is found among the orders instead of being placed again
+	e, err := x.Iceberg(context.Background(), zb.OrderRequest{Symbol: "btc_usdt", Price: 11010, Amount: 0.2, TradeType: zb.Sell}, IcebergParams{Visible: 0.1, PollInterval: 5 * time.Millisecond})
+	assert.Nil(t, err)
+	waitFor(t, func() bool { return e.Progress().Orders == 1 })
+	time.Sleep(20 * time.Millisecond)
+	open, _ := paper.GetOpenOrders("btc_usdt", "", "")
+	assert.Len(t, open, 1)
+
+	paper.OnTrades("btc_usdt", []zb.Trade{{Price: 11010, Amount: 0.05, TradeType: zb.Buy}})
+	e.Cancel()
+	report := e.Wait()
+	assert.Equal(t, Cancelled, report.Status)
+	assert.Len(t, report.Orders, 1)
+	assert.InDelta(t, 0.05, report.Filled, epsilon)
+	open, _ = paper.GetOpenOrders("btc_usdt", "", "")
+	assert.Empty(t, open)
+}
+
+func TestProfile(t *testing.T) {
+	start := time.Date(2018, 1, 22, 10, 0, 0, 0, time.UTC)
+	millis := func(t time.Time) uint64 { return uint64(t.UnixNano() / int64(time.Millisecond)) }
+	klines := []zb.Kline{
+		{Time: millis(start.Add(-24 * time.Hour)), Volume: 1},
+		{Time: millis(start.Add(-24*time.Hour + 30*time.Minute)), Volume: 3},
+		{Time: millis(start.Add(-48*time.Hour + 59*time.Minute)), Volume: 4},
+		{Time: millis(start.Add(-23 * time.Hour)), Volume: 100},
+	}
+	assert.Equal(t, []float64{0.125, 0.875}, Profile(klines, start, 30*time.Minute, 2))
+	assert.Equal(t, []float64{0.5, 0.5}, Profile(nil, start, 30*time.Minute, 2))
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("Condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
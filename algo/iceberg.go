@@ -0,0 +1,37 @@
+package algo
+
+import (
+	"context"
+	"github.com/berryland/zb"
+	"math"
+	"time"
+)
+
+type IcebergParams struct {
+	// Visible is the amount shown in the order book at a time
+	Visible      float64
+	PollInterval time.Duration
+}
+
+// Iceberg rests request.Amount at request.Price, showing at most params.Visible of it
+// at a time. The next part is placed once the shown one has filled.
+func (x *Executor) Iceberg(ctx context.Context, request zb.OrderRequest, params IcebergParams) (*Execution, error) {
+	if request.Price <= 0 {
+		return nil, &zb.ApiError{Code: zb.InvalidPrice, Message: "Iceberg orders need a price"}
+	}
+	if params.Visible <= 0 {
+		return nil, &zb.ApiError{Code: zb.InvalidAmount, Message: "Visible amount must be positive"}
+	}
+	return x.start(ctx, request, params.PollInterval, func(ctx context.Context, e *Execution) error {
+		e.setScheduled(request.Amount)
+		for !e.wait(ctx, time.Now()) {
+			if _, paused := e.state(ctx); !paused && !e.hasWorking() {
+				if err := e.place(math.Min(params.Visible, e.remaining()), request.Price); err != nil {
+					return err
+				}
+			}
+			e.sleep(ctx, e.poll)
+		}
+		return nil
+	})
+}
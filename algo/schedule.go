@@ -0,0 +1,166 @@
+package algo
+
+import (
+	"context"
+	"github.com/berryland/zb"
+	"time"
+)
+
+const (
+	day             = 24 * time.Hour
+	defaultVWAPDays = 7
+)
+
+type TWAPParams struct {
+	Duration time.Duration
+	Slices   int
+	// PollInterval is how often child orders are checked, a second by default
+	PollInterval time.Duration
+}
+
+type VWAPParams struct {
+	Duration time.Duration
+	Slices   int
+	// Period of the klines the volume profile is built from, it should not be longer than a slice
+	Period zb.Period
+	// Days of klines to build the profile from, 7 by default
+	Days         int
+	PollInterval time.Duration
+}
+
+// TWAP buys or sells request.Amount in equal slices over params.Duration. Each slice
+// takes liquidity at the best opposite price, and request.Price limits how far it goes,
+// 0 meaning no limit. What a slice leaves unfilled is carried into the next one.
+func (x *Executor) TWAP(ctx context.Context, request zb.OrderRequest, params TWAPParams) (*Execution, error) {
+	if params.Duration <= 0 || params.Slices <= 0 {
+		return nil, &zb.ApiError{Code: zb.InvalidArgument, Message: "Duration and slices must be positive"}
+	}
+	weights := make([]float64, params.Slices)
+	for i := range weights {
+		weights[i] = 1 / float64(params.Slices)
+	}
+	return x.start(ctx, request, params.PollInterval, func(ctx context.Context, e *Execution) error {
+		return e.schedule(ctx, weights, params.Duration)
+	})
+}
+
+// VWAP works like TWAP but sizes the slices after the volume traded at the same time of
+// day, UTC, over the last params.Days days.
+func (x *Executor) VWAP(ctx context.Context, request zb.OrderRequest, params VWAPParams) (*Execution, error) {
+	if params.Duration <= 0 || params.Slices <= 0 {
+		return nil, &zb.ApiError{Code: zb.InvalidArgument, Message: "Duration and slices must be positive"}
+	}
+	if params.Days <= 0 {
+		params.Days = defaultVWAPDays
+	}
+
+	now := time.Now()
+	to := uint64(now.UnixNano() / int64(time.Millisecond))
+	from := to - uint64(time.Duration(params.Days)*day/time.Millisecond)
+	klines, _, err := zb.BackfillKlines(ctx, x.market, request.Symbol, params.Period, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	weights := Profile(klines, now, params.Duration/time.Duration(params.Slices), params.Slices)
+	return x.start(ctx, request, params.PollInterval, func(ctx context.Context, e *Execution) error {
+		return e.schedule(ctx, weights, params.Duration)
+	})
+}
+
+// Profile splits the volume of klines by the time of day, UTC, of slices consecutive
+// intervals from start, and returns each interval's share. Without any volume every
+// interval gets the same share.
+func Profile(klines []zb.Kline, start time.Time, interval time.Duration, slices int) []float64 {
+	weights := make([]float64, slices)
+	if slices <= 0 {
+		return weights
+	}
+
+	dayMillis := uint64(day / time.Millisecond)
+	intervalMillis := uint64(interval / time.Millisecond)
+	first := uint64(start.UnixNano()/int64(time.Millisecond)) % dayMillis
+	total := 0.0
+	for _, k := range klines {
+		offset := (k.Time%dayMillis + dayMillis - first) % dayMillis
+		if intervalMillis == 0 || offset/intervalMillis >= uint64(slices) {
+			continue
+		}
+		weights[offset/intervalMillis] += k.Volume
+		total += k.Volume
+	}
+
+	for i := range weights {
+		if total > 0 {
+			weights[i] /= total
+		} else {
+			weights[i] = 1 / float64(slices)
+		}
+	}
+	return weights
+}
+
+// schedule works through weights, one slice of duration each. At the start of a slice
+// the working child order is cancelled and whatever is behind the cumulative target is
+// placed at a marketable price. Slices that start while paused are skipped and caught up
+// by the next one.
+func (e *Execution) schedule(ctx context.Context, weights []float64, duration time.Duration) error {
+	interval := duration / time.Duration(len(weights))
+	cumulative := 0.0
+	for i, weight := range weights {
+		if e.wait(ctx, e.start.Add(time.Duration(i)*interval)) {
+			return nil
+		}
+
+		cumulative += weight
+		target := e.request.Amount * cumulative
+		if i == len(weights)-1 {
+			target = e.request.Amount
+		}
+		e.setScheduled(target)
+		if _, paused := e.state(ctx); paused {
+			continue
+		}
+
+		e.cancelWorking()
+		if e.hasWorking() {
+			continue
+		}
+		price, err := e.takerPrice()
+		if err != nil || price <= 0 {
+			continue
+		}
+		if err := e.place(target-(e.request.Amount-e.remaining()), price); err != nil {
+			return err
+		}
+	}
+	e.wait(ctx, e.start.Add(duration))
+	return nil
+}
+
+// wait polls the working child order until until, and reports whether the algo is over
+// because it was cancelled or has filled. The working order is cancelled on Pause.
+func (e *Execution) wait(ctx context.Context, until time.Time) bool {
+	for {
+		stop, paused := e.state(ctx)
+		if stop {
+			return true
+		}
+		if paused {
+			e.cancelWorking()
+		}
+		e.sync()
+		if e.remaining() <= epsilon {
+			return true
+		}
+
+		left := until.Sub(time.Now())
+		if left <= 0 {
+			return false
+		}
+		if left > e.poll {
+			left = e.poll
+		}
+		e.sleep(ctx, left)
+	}
+}
@@ -0,0 +1,163 @@
+// Package backtest runs trading strategies over historical zb data.
+//
+// A strategy receives Kline, Trade and Depth events through the Strategy interface and
+// trades through the zb.Trading methods of a zb.PaperClient running on simulated time.
+// The same strategy can then be handed a RestClient to trade for real.
+package backtest
+
+import (
+	"github.com/berryland/zb"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Strategy interface {
+	// OnKline is called once the bar is closed, kline.Time being its open time
+	OnKline(symbol string, period zb.Period, kline zb.Kline)
+	OnTrades(symbol string, trades []zb.Trade)
+	OnDepth(symbol string, depth zb.Depth)
+}
+
+// BaseStrategy ignores every event, embed it to implement only some of Strategy.
+type BaseStrategy struct{}
+
+func (BaseStrategy) OnKline(symbol string, period zb.Period, kline zb.Kline) {}
+func (BaseStrategy) OnTrades(symbol string, trades []zb.Trade)               {}
+func (BaseStrategy) OnDepth(symbol string, depth zb.Depth)                   {}
+
+// Follow drives strategy with the live depth and trades of symbol. zb does not stream
+// klines, so OnKline is never called.
+func Follow(ws zb.Streamer, symbol string, strategy Strategy) {
+	ws.SubscribeDepth(symbol, func(depth zb.Depth) {
+		strategy.OnDepth(symbol, depth)
+	})
+	ws.SubscribeTrades(symbol, func(trades []zb.Trade) {
+		strategy.OnTrades(symbol, trades)
+	})
+}
+
+type Config struct {
+	// Balances held at the start, keyed by coin, e.g. "usdt"
+	Balances map[string]float64
+	Fee      float64
+	Slippage float64
+	Latency  time.Duration
+	// Quote is the coin the equity curve is valued in, "usdt" by default
+	Quote string
+	// Interval is the spacing of the equity curve, one hour by default
+	Interval time.Duration
+}
+
+const (
+	defaultQuote    = "usdt"
+	defaultInterval = time.Hour
+)
+
+type Backtest struct {
+	config Config
+	paper  *zb.PaperClient
+	now    uint64
+	prices map[string]float64
+}
+
+func New(config Config) *Backtest {
+	if config.Quote == "" {
+		config.Quote = defaultQuote
+	}
+	config.Quote = strings.ToLower(config.Quote)
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+	}
+
+	b := &Backtest{config: config, paper: zb.NewPaperClient(config.Balances, config.Fee), prices: map[string]float64{}}
+	b.paper.SetSlippage(config.Slippage)
+	b.paper.SetLatency(config.Latency)
+	b.paper.SetClock(func() uint64 {
+		return b.now
+	})
+	return b
+}
+
+// Trading returns the simulated exchange strategies place their orders with. It keeps
+// its orders and balances across runs.
+func (b *Backtest) Trading() *zb.PaperClient {
+	return b.paper
+}
+
+// Run feeds events to the simulator and then to strategy, in time order. The simulator
+// sees each event first, so orders placed in reaction to an event can only fill on
+// later ones.
+func (b *Backtest) Run(strategy Strategy, events []Event) Result {
+	events = append([]Event(nil), events...)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time < events[j].Time })
+
+	interval := uint64(b.config.Interval / time.Millisecond)
+	var equity []EquityPoint
+	next := uint64(0)
+	for _, e := range events {
+		if e.Time > b.now {
+			b.now = e.Time
+		}
+
+		switch {
+		case e.Kline != nil:
+			b.paper.OnKline(e.Symbol, e.Period, *e.Kline)
+			b.prices[e.Symbol] = e.Kline.Close
+			strategy.OnKline(e.Symbol, e.Period, *e.Kline)
+		case e.Depth != nil:
+			b.paper.OnDepth(e.Symbol, *e.Depth)
+			if mid, ok := midPrice(*e.Depth); ok {
+				b.prices[e.Symbol] = mid
+			}
+			strategy.OnDepth(e.Symbol, *e.Depth)
+		case len(e.Trades) > 0:
+			b.paper.OnTrades(e.Symbol, e.Trades)
+			b.prices[e.Symbol] = e.Trades[len(e.Trades)-1].Price
+			strategy.OnTrades(e.Symbol, e.Trades)
+		}
+
+		if b.now >= next {
+			equity = append(equity, EquityPoint{Time: b.now, Equity: b.Equity()})
+			next = b.now - b.now%interval + interval
+		}
+	}
+	if len(equity) > 0 && equity[len(equity)-1].Time != b.now {
+		equity = append(equity, EquityPoint{Time: b.now, Equity: b.Equity()})
+	}
+
+	return newResult(equity, b.paper.Fills(), b.paper.Fees(), b.config.Interval)
+}
+
+// Equity values every coin held, frozen or not, at the last price seen for its market
+// against the quote coin. Coins without such a market count as nothing.
+func (b *Backtest) Equity() float64 {
+	account, _ := b.paper.GetAccount("", "")
+	total := 0.0
+	for coin, amount := range account.Totals() {
+		if coin == b.config.Quote {
+			total += amount
+		} else if price, ok := b.prices[coin+"_"+b.config.Quote]; ok {
+			total += amount * price
+		}
+	}
+	return total
+}
+
+func midPrice(depth zb.Depth) (float64, bool) {
+	if len(depth.Asks) == 0 || len(depth.Bids) == 0 {
+		return 0, false
+	}
+	ask, bid := depth.Asks[0].Price, depth.Bids[0].Price
+	for _, e := range depth.Asks {
+		if e.Price < ask {
+			ask = e.Price
+		}
+	}
+	for _, e := range depth.Bids {
+		if e.Price > bid {
+			bid = e.Price
+		}
+	}
+	return (ask + bid) / 2, true
+}
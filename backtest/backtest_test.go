@@ -0,0 +1,125 @@
+package backtest
+
+import (
+	"github.com/berryland/zb"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// swing buys 1 btc whenever a bar closes below 100 and sells it once one closes above
+// 110, with limits 5% through the close so the orders fill at the next open.
+type swing struct {
+	BaseStrategy
+	trading zb.Trading
+	holding bool
+}
+
+func (s *swing) OnKline(symbol string, period zb.Period, kline zb.Kline) {
+	if !s.holding && kline.Close < 100 {
+		s.trading.PlaceOrder(symbol, kline.Close*1.05, 1, zb.Buy, "", "")
+		s.holding = true
+	} else if s.holding && kline.Close > 110 {
+		// the 0.1% fee is charged on the btc bought
+		s.trading.PlaceOrder(symbol, kline.Close*0.95, 0.999, zb.Sell, "", "")
+		s.holding = false
+	}
+}
+
+func TestBacktest_Run(t *testing.T) {
+	hour := uint64(time.Hour / time.Millisecond)
+	closes := []float64{98, 105, 112, 108, 99, 95, 111, 90}
+	var klines []zb.Kline
+	open := 100.0
+	for i, c := range closes {
+		klines = append(klines, zb.Kline{Time: uint64(i) * hour, Open: open, High: math.Max(open, c) + 1, Low: math.Min(open, c) - 1, Close: c, Volume: 10})
+		open = c
+	}
+
+	b := New(Config{Balances: map[string]float64{"usdt": 1000}, Fee: 0.001})
+	result := b.Run(&swing{trading: b.Trading()}, KlineEvents("btc_usdt", zb.OneHour, klines))
+
+	assert.Len(t, result.Fills, 4)
+	if assert.Len(t, result.Trades, 2) {
+		assert.InDelta(t, (112*0.999-98/0.999)*0.999, result.Trades[0].Profit, 1e-6)
+		assert.InDelta(t, (111*0.999-99/0.999)*0.999, result.Trades[1].Profit, 1e-6)
+	}
+	assert.Equal(t, 1.0, result.Stats.WinRate)
+	assert.Len(t, result.Equity, len(closes))
+	assert.Equal(t, 1000.0, result.Stats.Start)
+
+	account, _ := b.Trading().GetAccount("", "")
+	assert.InDelta(t, result.Stats.End, account.Totals()["usdt"]+account.Totals()["btc"]*90, 1e-6)
+	assert.True(t, result.Stats.MaxDrawdown > 0)
+	assert.NotZero(t, result.Stats.Sharpe)
+}
+
+func TestBacktest_Latency(t *testing.T) {
+	b := New(Config{Balances: map[string]float64{"usdt": 1000}, Latency: time.Second, Slippage: 0.01})
+	trading := b.Trading()
+	events := Merge(
+		DepthEvents("btc_usdt", []zb.Depth{
+			{Time: 1, Asks: []zb.DepthEntry{{Price: 100, Volume: 5}}, Bids: []zb.DepthEntry{{Price: 99, Volume: 5}}},
+			{Time: 2, Asks: []zb.DepthEntry{{Price: 100, Volume: 5}}, Bids: []zb.DepthEntry{{Price: 99, Volume: 5}}},
+		}),
+	)
+
+	placed := false
+	result := b.Run(&depthTaker{trading: trading, placed: &placed}, events)
+	if assert.Len(t, result.Fills, 1) {
+		assert.Equal(t, uint64(2000), result.Fills[0].Time)
+		assert.InDelta(t, 101, result.Fills[0].Price, 1e-9)
+	}
+}
+
+// depthTaker buys at 102 on the first depth snapshot
+type depthTaker struct {
+	BaseStrategy
+	trading zb.Trading
+	placed  *bool
+}
+
+func (s *depthTaker) OnDepth(symbol string, depth zb.Depth) {
+	if !*s.placed {
+		s.trading.PlaceOrder(symbol, 102, 1, zb.Buy, "", "")
+		*s.placed = true
+	}
+}
+
+func TestReadRecorded(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "backtest")
+	defer os.RemoveAll(dir)
+
+	write := func(stream, name, content string) {
+		path := filepath.Join(dir, stream, "v1", "date=2018-01-22", name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		ioutil.WriteFile(path, []byte(content), 0644)
+	}
+	write("trades", "btc_usdt-000000.csv", "symbol,received,id,time,side,price,amount\nbtc_usdt,1516579200500,1,1516579200,buy,100,1\nbtc_usdt,1516579200500,2,1516579200,sell,99,2\nbtc_usdt,1516579201500,3,1516579201,buy,101,1\n")
+	write("trades", "btc_usdt-010000.csv.part", "symbol,received,id,time,side,price,amount\nbtc_usdt,1516582800500,4,1516582800,buy,100,1\n")
+	write("depth", "btc_usdt-000000.csv", "symbol,received,time,side,level,price,volume\nbtc_usdt,1516579201000,1516579200,ask,0,101,1\nbtc_usdt,1516579201000,1516579200,bid,0,99,2\n")
+	write("klines", "btc_usdt-000000.csv", "symbol,received,period,time,open,high,low,close,volume\nbtc_usdt,1516579300000,1min,1516579140000,1,2,0.5,1.5,10\n")
+	// a restarted recorder repeats what it had not yet marked as written
+	write("trades", "btc_usdt-000000-1.csv", "symbol,received,id,time,side,price,amount\nbtc_usdt,1516579202500,3,1516579201,buy,101,1\n")
+	write("klines", "btc_usdt-000000-1.csv", "symbol,received,period,time,open,high,low,close,volume\nbtc_usdt,1516579400000,1min,1516579140000,1,2,0.5,1.5,10\n")
+	write("trades", "eth_usdt-000000.csv", "symbol,received,id,time,side,price,amount\neth_usdt,1516579200500,1,1516579200,buy,100,1\n")
+
+	events, err := ReadRecorded(dir, "btc_usdt")
+	assert.Nil(t, err)
+	if assert.Len(t, events, 4) {
+		assert.Equal(t, uint64(1516579200000), events[0].Time)
+		assert.Equal(t, 1.5, events[0].Kline.Close)
+		assert.Equal(t, uint64(1516579200500), events[1].Time)
+		assert.Equal(t, []zb.Trade{{Id: 1, TradeType: zb.Buy, Price: 100, Amount: 1, Time: 1516579200}, {Id: 2, TradeType: zb.Sell, Price: 99, Amount: 2, Time: 1516579200}}, events[1].Trades)
+		assert.Equal(t, &zb.Depth{Time: 1516579200, Asks: []zb.DepthEntry{{Price: 101, Volume: 1}}, Bids: []zb.DepthEntry{{Price: 99, Volume: 2}}}, events[2].Depth)
+		assert.Equal(t, uint64(3), events[3].Trades[0].Id)
+	}
+
+	write("trades", "btc_usdt-020000.csv", "symbol,received,id,time,side,price,amount\nbtc_usdt,1516586400500,5,1516586400,both,100,1\n")
+	_, err = ReadRecorded(dir, "btc_usdt")
+	assert.Contains(t, err.Error(), "Invalid trade side: both")
+}
@@ -0,0 +1,265 @@
+package backtest
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"github.com/berryland/zb"
+	"github.com/berryland/zb/recorder"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is one Kline, batch of Trades or Depth snapshot of Symbol. Time, in
+// milliseconds, is when the event became known: the close of a bar, or when trades and
+// depth were received.
+type Event struct {
+	Time   uint64
+	Symbol string
+	Period zb.Period
+	Kline  *zb.Kline
+	Trades []zb.Trade
+	Depth  *zb.Depth
+}
+
+func KlineEvents(symbol string, period zb.Period, klines []zb.Kline) []Event {
+	step := uint64(period.Duration() / time.Millisecond)
+	events := make([]Event, 0, len(klines))
+	for i := range klines {
+		k := klines[i]
+		events = append(events, Event{Time: k.Time + step, Symbol: symbol, Period: period, Kline: &k})
+	}
+	return events
+}
+
+// TradeEvents batches trades of the same time into one event.
+func TradeEvents(symbol string, trades []zb.Trade) []Event {
+	var events []Event
+	for _, t := range trades {
+		at := millis(t.Time)
+		if n := len(events); n > 0 && events[n-1].Time == at {
+			events[n-1].Trades = append(events[n-1].Trades, t)
+			continue
+		}
+		events = append(events, Event{Time: at, Symbol: symbol, Trades: []zb.Trade{t}})
+	}
+	return events
+}
+
+func DepthEvents(symbol string, depths []zb.Depth) []Event {
+	events := make([]Event, 0, len(depths))
+	for i := range depths {
+		d := depths[i]
+		events = append(events, Event{Time: millis(d.Time), Symbol: symbol, Depth: &d})
+	}
+	return events
+}
+
+// Merge combines event streams into one in time order, keeping the order of events
+// of equal time.
+func Merge(streams ...[]Event) []Event {
+	var events []Event
+	for _, stream := range streams {
+		events = append(events, stream...)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time < events[j].Time })
+	return events
+}
+
+// millis converts the second timestamps zb uses for trades and depth to milliseconds.
+func millis(t uint64) uint64 {
+	if t < 1e12 {
+		return t * 1000
+	}
+	return t
+}
+
+// DownloadKlines fetches the klines of symbol between from and to, in milliseconds.
+func DownloadKlines(ctx context.Context, market zb.MarketData, symbol string, period zb.Period, from, to uint64) ([]Event, error) {
+	klines, _, err := zb.BackfillKlines(ctx, market, symbol, period, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return KlineEvents(symbol, period, klines), nil
+}
+
+// ReadRecorded loads the trades, depth and klines of symbol written to dir by a
+// recorder in CSV format. Trades and depth are timed by when they were received. Trades
+// and klines a restarted recorder wrote again are only read once.
+func ReadRecorded(dir string, symbol string) ([]Event, error) {
+	var events []Event
+	seen := map[uint64]bool{}
+	err := readRecorded(dir, "trades", symbol, func(row map[string]string) error {
+		var t zb.Trade
+		var received uint64
+		err := parseAll(
+			parseUint(row["received"], &received), parseUint(row["id"], &t.Id), parseUint(row["time"], &t.Time),
+			parseFloat(row["price"], &t.Price), parseFloat(row["amount"], &t.Amount), parseTradeType(row["side"], &t.TradeType))
+		if err != nil {
+			return err
+		}
+		if seen[t.Id] {
+			return nil
+		}
+		seen[t.Id] = true
+		if n := len(events); n > 0 && events[n-1].Time == received {
+			events[n-1].Trades = append(events[n-1].Trades, t)
+		} else {
+			events = append(events, Event{Time: received, Symbol: symbol, Trades: []zb.Trade{t}})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var depths []Event
+	err = readRecorded(dir, "depth", symbol, func(row map[string]string) error {
+		var received, at, level uint64
+		var entry zb.DepthEntry
+		err := parseAll(
+			parseUint(row["received"], &received), parseUint(row["time"], &at), parseUint(row["level"], &level),
+			parseFloat(row["price"], &entry.Price), parseFloat(row["volume"], &entry.Volume))
+		if err != nil {
+			return err
+		}
+		if row["side"] != "ask" && row["side"] != "bid" {
+			return errors.New("Invalid depth side: " + row["side"])
+		}
+		n := len(depths)
+		if n == 0 || depths[n-1].Time != received || depths[n-1].Depth.Time != at {
+			depths = append(depths, Event{Time: received, Symbol: symbol, Depth: &zb.Depth{Time: at}})
+			n++
+		}
+		if row["side"] == "ask" {
+			depths[n-1].Depth.Asks = append(depths[n-1].Depth.Asks, entry)
+		} else {
+			depths[n-1].Depth.Bids = append(depths[n-1].Depth.Bids, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var klines []Event
+	seenKlines := map[zb.Period]map[uint64]bool{}
+	err = readRecorded(dir, "klines", symbol, func(row map[string]string) error {
+		var k zb.Kline
+		period, err := zb.ParsePeriod(row["period"])
+		if err != nil {
+			return err
+		}
+		err = parseAll(
+			parseUint(row["time"], &k.Time), parseFloat(row["open"], &k.Open), parseFloat(row["high"], &k.High),
+			parseFloat(row["low"], &k.Low), parseFloat(row["close"], &k.Close), parseFloat(row["volume"], &k.Volume))
+		if err != nil {
+			return err
+		}
+		if seenKlines[period] == nil {
+			seenKlines[period] = map[uint64]bool{}
+		}
+		if seenKlines[period][k.Time] {
+			return nil
+		}
+		seenKlines[period][k.Time] = true
+		klines = append(klines, KlineEvents(symbol, period, []zb.Kline{k})...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return Merge(events, depths, klines), nil
+}
+
+// readRecorded hands every row of the finished CSV files of one stream to handler,
+// keyed by column name and in time order: by file name, a file of a restarted recorder
+// after the one it shares its interval with.
+func readRecorded(dir string, stream string, symbol string, handler func(row map[string]string) error) error {
+	pattern := filepath.Join(dir, stream, "v"+strconv.Itoa(recorder.SchemaVersion), "date=*", symbol+"-*.csv")
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return strings.TrimSuffix(paths[i], ".csv") < strings.TrimSuffix(paths[j], ".csv")
+	})
+
+	for _, path := range paths {
+		if err := readCsv(path, handler); err != nil {
+			return errors.New(path + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+func readCsv(path string, handler func(row map[string]string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			row[name] = record[i]
+		}
+		if err := handler(row); err != nil {
+			return err
+		}
+	}
+}
+
+func parseUint(s string, value *uint64) error {
+	parsed, err := strconv.ParseUint(s, 10, 64)
+	*value = parsed
+	return err
+}
+
+func parseFloat(s string, value *float64) error {
+	parsed, err := strconv.ParseFloat(s, 64)
+	*value = parsed
+	return err
+}
+
+func parseTradeType(s string, value *zb.TradeType) error {
+	switch s {
+	case "buy":
+		*value = zb.Buy
+	case "sell":
+		*value = zb.Sell
+	default:
+		return errors.New("Invalid trade side: " + s)
+	}
+	return nil
+}
+
+func parseAll(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package backtest
+
+import (
+	"github.com/berryland/zb"
+	"math"
+	"time"
+)
+
+type EquityPoint struct {
+	Time   uint64
+	Equity float64
+}
+
+// ClosedTrade is a sell that reduced a position bought during the backtest. Entry is the
+// average cost per coin of the position, Exit the proceeds per coin after fees, and
+// Profit is in the quote coin of Symbol.
+type ClosedTrade struct {
+	Symbol string
+	Time   uint64
+	Amount float64
+	Entry  float64
+	Exit   float64
+	Profit float64
+}
+
+type Stats struct {
+	Start float64
+	End   float64
+	// Return is End over Start minus one
+	Return float64
+	// Sharpe is the annualized Sharpe ratio of the equity curve returns, with no risk free rate
+	Sharpe float64
+	// MaxDrawdown is the largest fall from a peak of the equity curve, as a fraction of the peak
+	MaxDrawdown float64
+	// WinRate is the share of closed trades with a positive profit
+	WinRate float64
+	Trades  int
+	Fees    map[string]float64
+}
+
+type Result struct {
+	Equity []EquityPoint
+	Fills  []zb.Fill
+	Trades []ClosedTrade
+	Stats  Stats
+}
+
+func newResult(equity []EquityPoint, fills []zb.Fill, fees map[string]float64, interval time.Duration) Result {
+	r := Result{Equity: equity, Fills: fills, Trades: closeTrades(fills)}
+	r.Stats.Fees = fees
+	r.Stats.Trades = len(r.Trades)
+	if len(equity) > 0 {
+		r.Stats.Start, r.Stats.End = equity[0].Equity, equity[len(equity)-1].Equity
+		if r.Stats.Start != 0 {
+			r.Stats.Return = r.Stats.End/r.Stats.Start - 1
+		}
+	}
+	r.Stats.Sharpe = sharpe(equity, interval)
+	r.Stats.MaxDrawdown = maxDrawdown(equity)
+
+	wins := 0
+	for _, t := range r.Trades {
+		if t.Profit > 0 {
+			wins++
+		}
+	}
+	if len(r.Trades) > 0 {
+		r.Stats.WinRate = float64(wins) / float64(len(r.Trades))
+	}
+	return r
+}
+
+// closeTrades matches sells against the average cost of what was bought before them.
+// Selling coins held from the start closes nothing.
+func closeTrades(fills []zb.Fill) []ClosedTrade {
+	type position struct {
+		amount float64
+		cost   float64
+	}
+	positions := map[string]*position{}
+
+	var trades []ClosedTrade
+	for _, f := range fills {
+		p, ok := positions[f.Symbol]
+		if !ok {
+			p = &position{}
+			positions[f.Symbol] = p
+		}
+
+		if f.TradeType == zb.Buy {
+			p.amount += f.Amount - f.Fee
+			p.cost += f.Amount * f.Price
+			continue
+		}
+
+		closed := math.Min(f.Amount, p.amount)
+		if closed <= 0 {
+			continue
+		}
+		entry := p.cost / p.amount
+		exit := (f.Amount*f.Price - f.Fee) / f.Amount
+		trades = append(trades, ClosedTrade{Symbol: f.Symbol, Time: f.Time, Amount: closed, Entry: entry, Exit: exit, Profit: (exit - entry) * closed})
+		p.amount -= closed
+		p.cost -= entry * closed
+	}
+	return trades
+}
+
+func sharpe(equity []EquityPoint, interval time.Duration) float64 {
+	var returns []float64
+	for i := 1; i < len(equity); i++ {
+		if equity[i-1].Equity != 0 {
+			returns = append(returns, equity[i].Equity/equity[i-1].Equity-1)
+		}
+	}
+	if len(returns) < 2 {
+		return 0
+	}
+
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+	variance := 0.0
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	deviation := math.Sqrt(variance / float64(len(returns)-1))
+	if deviation == 0 {
+		return 0
+	}
+	return mean / deviation * math.Sqrt(float64(365*24*time.Hour)/float64(interval))
+}
+
+func maxDrawdown(equity []EquityPoint) float64 {
+	peak, drawdown := 0.0, 0.0
+	for _, p := range equity {
+		if p.Equity > peak {
+			peak = p.Equity
+		}
+		if peak > 0 && (peak-p.Equity)/peak > drawdown {
+			drawdown = (peak - p.Equity) / peak
+		}
+	}
+	return drawdown
+}
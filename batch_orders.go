@@ -0,0 +1,201 @@
+package zb
+
+import (
+	"fmt"
+	json "github.com/buger/jsonparser"
+	"strconv"
+	"strings"
+)
+
+const maxBatchOrders = 10
+
+type OrderRequest struct {
+	Symbol    string
+	Price     float64
+	Amount    float64
+	TradeType TradeType
+}
+
+type LegStatus uint8
+
+const (
+	// LegOk means the order was placed or cancelled
+	LegOk LegStatus = iota
+	// LegFailed means zb rejected the leg, nothing changed on the exchange
+	LegFailed
+	// LegUnknown means the request may or may not have reached zb, e.g. after a network
+	// error, and the outcome has to be checked with GetOrder or GetOpenOrders
+	LegUnknown
+)
+
+type OrderResult struct {
+	Request OrderRequest
+	Id      uint64
+	Status  LegStatus
+	Err     error
+}
+
+type CancelResult struct {
+	Id     uint64
+	Status LegStatus
+	Err    error
+}
+
+// BatchError is returned when some legs of a batch did not succeed, the results
+// tell which ones.
+type BatchError struct {
+	Failed  int
+	Unknown int
+	Total   int
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("Batch partially failed (%d failed, %d unknown of %d)", e.Failed, e.Unknown, e.Total)
+}
+
+// PlaceOrders places requests through zb's batch endpoint, one call per symbol, side and
+// chunk of 10 orders. Results are in the order of requests.
+func (c *RestClient) PlaceOrders(requests []OrderRequest, accessKey, secretKey string) ([]OrderResult, error) {
+	results := make([]OrderResult, len(requests))
+	groups := map[string][]int{}
+	var keys []string
+	for i, r := range requests {
+		results[i].Request = r
+		key := r.Symbol + "/" + strconv.Itoa(int(r.TradeType))
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	for _, key := range keys {
+		indexes := groups[key]
+		for from := 0; from < len(indexes); from += maxBatchOrders {
+			to := from + maxBatchOrders
+			if to > len(indexes) {
+				to = len(indexes)
+			}
+			c.placeBatch(requests, indexes[from:to], results, accessKey, secretKey)
+		}
+	}
+
+	return results, batchError(len(results), func(i int) LegStatus { return results[i].Status })
+}
+
+func (c *RestClient) placeBatch(requests []OrderRequest, indexes []int, results []OrderResult, accessKey, secretKey string) {
+	var params []string
+	for _, i := range indexes {
+		params = append(params, "["+strconv.FormatFloat(requests[i].Price, 'f', -1, 64)+","+strconv.FormatFloat(requests[i].Amount, 'f', -1, 64)+"]")
+	}
+	first := requests[indexes[0]]
+	q := map[string]string{
+		"market":      first.Symbol,
+		"tradeType":   strconv.Itoa(int(first.TradeType)),
+		"tradeParams": "[" + strings.Join(params, ",") + "]",
+		"accesskey":   accessKey,
+		"method":      "orderMoreV2",
+	}
+	u := buildUrl(c.tradeApiUrl+"orderMoreV2", q)
+	sign(u, secretKey)
+
+	fail := func(status LegStatus, err error) {
+		for _, i := range indexes {
+			results[i].Status, results[i].Err = status, err
+		}
+	}
+
+	resp, err := c.doGet(u.String())
+	if err != nil {
+		fail(LegUnknown, err)
+		return
+	}
+
+	bytes := resp.ReadBytes()
+	err = extractTradeError(bytes)
+	if apiError, ok := err.(*ApiError); ok && apiError.Code != GeneralError {
+		fail(LegFailed, err)
+		return
+	}
+	if err != nil {
+		fail(LegUnknown, err)
+		return
+	}
+
+	n := 0
+	json.ArrayEach(bytes, func(value []byte, dataType json.ValueType, offset int, err error) {
+		if n >= len(indexes) {
+			return
+		}
+		results[indexes[n]].Id, results[indexes[n]].Status, results[indexes[n]].Err = parseBatchLeg(value)
+		n++
+	}, "result")
+	for ; n < len(indexes); n++ {
+		results[indexes[n]].Status, results[indexes[n]].Err = LegUnknown, &ApiError{Code: GeneralError, Message: "Missing batch result"}
+	}
+}
+
+func parseBatchLeg(value []byte) (uint64, LegStatus, error) {
+	if err := extractTradeError(value); err != nil {
+		return 0, LegFailed, err
+	}
+	for _, key := range []string{"entrustId", "id"} {
+		if s, err := json.GetString(value, key); err == nil {
+			if id, err := strconv.ParseUint(s, 10, 64); err == nil {
+				return id, LegOk, nil
+			}
+		}
+		if id, err := json.GetInt(value, key); err == nil {
+			return uint64(id), LegOk, nil
+		}
+	}
+	return 0, LegUnknown, &ApiError{Code: GeneralError, Message: "Missing order id in " + string(value)}
+}
+
+// CancelOrders cancels ids one by one, zb has no batch cancellation.
+func (c *RestClient) CancelOrders(symbol string, ids []uint64, accessKey, secretKey string) ([]CancelResult, error) {
+	results := make([]CancelResult, len(ids))
+	for i, id := range ids {
+		results[i].Id = id
+		err := c.CancelOrder(symbol, id, accessKey, secretKey)
+		apiError, ok := err.(*ApiError)
+		switch {
+		case err == nil:
+			results[i].Status = LegOk
+		case ok && apiError.Code != GeneralError:
+			results[i].Status, results[i].Err = LegFailed, err
+		default:
+			results[i].Status, results[i].Err = LegUnknown, err
+		}
+	}
+	return results, batchError(len(results), func(i int) LegStatus { return results[i].Status })
+}
+
+// CancelAll cancels every open order of symbol.
+func (c *RestClient) CancelAll(symbol string, accessKey, secretKey string) ([]CancelResult, error) {
+	orders, err := c.GetOpenOrders(symbol, accessKey, secretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []uint64
+	for _, order := range orders {
+		ids = append(ids, order.Id)
+	}
+	return c.CancelOrders(symbol, ids, accessKey, secretKey)
+}
+
+func batchError(total int, status func(i int) LegStatus) error {
+	e := &BatchError{Total: total}
+	for i := 0; i < total; i++ {
+		switch status(i) {
+		case LegFailed:
+			e.Failed++
+		case LegUnknown:
+			e.Unknown++
+		}
+	}
+	if e.Failed == 0 && e.Unknown == 0 {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,67 @@
+package zb_test
+
+import (
+	"github.com/berryland/zb"
+	"github.com/berryland/zb/zbtest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRestClientOffline_PlaceOrders(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+
+	var requests []zb.OrderRequest
+	for i := 0; i < 12; i++ {
+		requests = append(requests, zb.OrderRequest{Symbol: "btc_usdt", Price: 10000 - float64(i), Amount: 0.1, TradeType: zb.Buy})
+	}
+	requests = append(requests, zb.OrderRequest{Symbol: "btc_usdt", Price: 11000, Amount: 0, TradeType: zb.Sell})
+	requests = append(requests, zb.OrderRequest{Symbol: "btc_usdt", Price: 11001, Amount: 0.1, TradeType: zb.Sell})
+
+	results, err := s.RestClient().PlaceOrders(requests, s.AccessKey, s.SecretKey)
+	assert.Equal(t, &zb.BatchError{Failed: 1, Total: 14}, err)
+	assert.Equal(t, 3, s.Requests("orderMoreV2"))
+	for i, result := range results {
+		if i == 12 {
+			assert.Equal(t, zb.LegFailed, result.Status)
+			assert.Equal(t, zb.InvalidAmount, result.Err.(*zb.ApiError).Code)
+			continue
+		}
+		assert.Equal(t, zb.LegOk, result.Status)
+		order, _ := s.Order(result.Id)
+		assert.Equal(t, requests[i].Price, order.Price)
+		assert.Equal(t, requests[i].TradeType, order.TradeType)
+	}
+
+	s.InjectError("orderMoreV2", zb.TooFrequent)
+	results, err = s.RestClient().PlaceOrders(requests[:2], s.AccessKey, s.SecretKey)
+	assert.Equal(t, &zb.BatchError{Failed: 2, Total: 2}, err)
+	assert.Equal(t, zb.TooFrequent, results[1].Err.(*zb.ApiError).Code)
+}
+
+func TestRestClientOffline_CancelAll(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	c := s.RestClient()
+
+	first, _ := c.PlaceOrder("btc_usdt", 10000, 0.1, zb.Buy, s.AccessKey, s.SecretKey)
+	c.PlaceOrder("btc_usdt", 10001, 0.1, zb.Buy, s.AccessKey, s.SecretKey)
+
+	results, err := c.CancelOrders("btc_usdt", []uint64{first, 42}, s.AccessKey, s.SecretKey)
+	assert.Equal(t, &zb.BatchError{Failed: 1, Total: 2}, err)
+	assert.Equal(t, zb.LegOk, results[0].Status)
+	assert.Equal(t, zb.LegFailed, results[1].Status)
+
+	// an unreadable response leaves it open whether the order was cancelled
+	s.InjectMalformed("cancelOrder")
+	results, err = c.CancelAll("btc_usdt", s.AccessKey, s.SecretKey)
+	assert.Equal(t, &zb.BatchError{Unknown: 1, Total: 1}, err)
+	assert.Equal(t, zb.LegUnknown, results[0].Status)
+
+	results, err = c.CancelAll("btc_usdt", s.AccessKey, s.SecretKey)
+	assert.Nil(t, err)
+	assert.Len(t, results, 1)
+
+	open, _ := c.GetOpenOrders("btc_usdt", s.AccessKey, s.SecretKey)
+	assert.Empty(t, open)
+}
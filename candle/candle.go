@@ -0,0 +1,165 @@
+// Package candle builds OHLCV bars of arbitrary periods from zb trades and resamples
+// kline series into coarser periods.
+//
+// Bars shorter than a day restart at every local midnight of the chosen location, so a
+// period that does not divide a day, e.g. 45 minutes, has a shorter last bar each day.
+// Bars of whole days start at local midnight and are counted from Monday 1970-01-05,
+// which makes weekly bars start on Mondays.
+package candle
+
+import (
+	"errors"
+	"github.com/berryland/zb"
+	"sort"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+var ErrInvalidPeriod = errors.New("Period must be positive and a whole number of days when longer than a day")
+
+func validate(period time.Duration) error {
+	if period <= 0 || period >= day && period%day != 0 {
+		return ErrInvalidPeriod
+	}
+	return nil
+}
+
+// Start returns the start, in milliseconds, of the bar of period containing t.
+func Start(t uint64, period time.Duration, location *time.Location) uint64 {
+	local := time.Unix(0, int64(t)*int64(time.Millisecond)).In(location)
+	y, m, d := local.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, location)
+
+	if period < day {
+		offset := local.Sub(midnight)
+		return millis(midnight.Add(offset - offset%period))
+	}
+
+	days := int64(period / day)
+	number := time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix()/86400 - 4
+	first := number - ((number%days)+days)%days
+	return millis(time.Date(1970, 1, 5+int(first), 0, 0, 0, 0, location))
+}
+
+func millis(t time.Time) uint64 {
+	return uint64(t.UnixNano() / int64(time.Millisecond))
+}
+
+// tradeMillis converts trade times, which zb sends in seconds, to milliseconds.
+func tradeMillis(t uint64) uint64 {
+	if t < 1e12 {
+		return t * 1000
+	}
+	return t
+}
+
+// Aggregator turns a time ordered stream of trades into bars. Periods without trades
+// produce no bar, and trades older than the current bar are dropped.
+type Aggregator struct {
+	period   time.Duration
+	location *time.Location
+	onClose  func(kline zb.Kline)
+	current  zb.Kline
+	open     bool
+}
+
+// NewAggregator calls onClose with each bar once a trade of a later bar arrives or Flush is called.
+func NewAggregator(period time.Duration, location *time.Location, onClose func(kline zb.Kline)) (*Aggregator, error) {
+	if err := validate(period); err != nil {
+		return nil, err
+	}
+	return &Aggregator{period: period, location: location, onClose: onClose}, nil
+}
+
+func (a *Aggregator) Add(trade zb.Trade) {
+	start := Start(tradeMillis(trade.Time), a.period, a.location)
+	if a.open && start < a.current.Time {
+		return
+	}
+	if a.open && start > a.current.Time {
+		a.Flush()
+	}
+
+	if !a.open {
+		a.current = zb.Kline{Time: start, Open: trade.Price, High: trade.Price, Low: trade.Price}
+		a.open = true
+	}
+	if trade.Price > a.current.High {
+		a.current.High = trade.Price
+	}
+	if trade.Price < a.current.Low {
+		a.current.Low = trade.Price
+	}
+	a.current.Close = trade.Price
+	a.current.Volume += trade.Amount
+}
+
+func (a *Aggregator) AddAll(trades []zb.Trade) {
+	for _, trade := range trades {
+		a.Add(trade)
+	}
+}
+
+// Current returns the bar still being built.
+func (a *Aggregator) Current() (zb.Kline, bool) {
+	return a.current, a.open
+}
+
+// Flush closes the bar being built, e.g. once its period has passed without new trades.
+func (a *Aggregator) Flush() {
+	if !a.open {
+		return
+	}
+	a.open = false
+	a.onClose(a.current)
+}
+
+// Aggregate builds every bar of trades at once.
+func Aggregate(trades []zb.Trade, period time.Duration, location *time.Location) ([]zb.Kline, error) {
+	var klines []zb.Kline
+	a, err := NewAggregator(period, location, func(kline zb.Kline) {
+		klines = append(klines, kline)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := append([]zb.Trade(nil), trades...)
+	sort.SliceStable(sorted, func(i, j int) bool { return tradeMillis(sorted[i].Time) < tradeMillis(sorted[j].Time) })
+	a.AddAll(sorted)
+	a.Flush()
+	return klines, nil
+}
+
+// Resample merges klines into bars of a coarser period, opening with the first kline
+// and closing with the last kline of each bar.
+func Resample(klines []zb.Kline, period time.Duration, location *time.Location) ([]zb.Kline, error) {
+	if err := validate(period); err != nil {
+		return nil, err
+	}
+
+	sorted := append([]zb.Kline(nil), klines...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time < sorted[j].Time })
+
+	var resampled []zb.Kline
+	for _, k := range sorted {
+		start := Start(k.Time, period, location)
+		last := len(resampled) - 1
+		if last < 0 || resampled[last].Time != start {
+			resampled = append(resampled, zb.Kline{Time: start, Open: k.Open, High: k.High, Low: k.Low, Close: k.Close, Volume: k.Volume})
+			continue
+		}
+
+		bar := &resampled[last]
+		if k.High > bar.High {
+			bar.High = k.High
+		}
+		if k.Low < bar.Low {
+			bar.Low = k.Low
+		}
+		bar.Close = k.Close
+		bar.Volume += k.Volume
+	}
+	return resampled, nil
+}
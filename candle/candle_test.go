@@ -0,0 +1,57 @@
+package candle
+
+import (
+	"github.com/berryland/zb"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func at(location *time.Location, value string) uint64 {
+	t, _ := time.ParseInLocation("2006-01-02 15:04", value, location)
+	return millis(t)
+}
+
+func TestStart(t *testing.T) {
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+
+	assert.Equal(t, at(time.UTC, "2018-01-15 03:00"), Start(at(time.UTC, "2018-01-15 05:59"), 3*time.Hour, time.UTC))
+	assert.Equal(t, at(time.UTC, "2018-01-15 23:15"), Start(at(time.UTC, "2018-01-15 23:59"), 45*time.Minute, time.UTC))
+	assert.Equal(t, at(shanghai, "2018-01-16 00:00"), Start(at(shanghai, "2018-01-16 07:59"), day, shanghai))
+	assert.Equal(t, at(time.UTC, "2018-01-15 00:00"), Start(at(time.UTC, "2018-01-21 23:59"), 7*day, time.UTC))
+}
+
+func TestAggregate(t *testing.T) {
+	base := at(time.UTC, "2018-01-15 00:00") / 1000
+	trades := []zb.Trade{
+		{Price: 10, Amount: 1, Time: base + 60},
+		{Price: 12, Amount: 2, Time: base + 120},
+		{Price: 9, Amount: 1, Time: base + 1800},
+		{Price: 11, Amount: 1, Time: base + 2700},
+	}
+
+	klines, err := Aggregate(trades, 45*time.Minute, time.UTC)
+	assert.Nil(t, err)
+	assert.Equal(t, []zb.Kline{
+		{Time: base * 1000, Open: 10, High: 12, Low: 9, Close: 9, Volume: 4},
+		{Time: (base + 2700) * 1000, Open: 11, High: 11, Low: 11, Close: 11, Volume: 1},
+	}, klines)
+
+	_, err = Aggregate(trades, 36*time.Hour, time.UTC)
+	assert.Equal(t, ErrInvalidPeriod, err)
+}
+
+func TestResample(t *testing.T) {
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	var hourly []zb.Kline
+	for i := uint64(0); i < 48; i++ {
+		hourly = append(hourly, zb.Kline{Time: at(shanghai, "2018-01-15 00:00") + i*3600000, Open: float64(i), High: float64(i) + 1, Low: float64(i) - 1, Close: float64(i) + 0.5, Volume: 1})
+	}
+
+	daily, err := Resample(hourly, day, shanghai)
+	assert.Nil(t, err)
+	assert.Equal(t, []zb.Kline{
+		{Time: at(shanghai, "2018-01-15 00:00"), Open: 0, High: 24, Low: -1, Close: 23.5, Volume: 24},
+		{Time: at(shanghai, "2018-01-16 00:00"), Open: 24, High: 48, Low: 23, Close: 47.5, Volume: 24},
+	}, daily)
+}
@@ -0,0 +1,293 @@
+package zb
+
+import (
+	"bufio"
+	"context"
+	stdjson "encoding/json"
+	"errors"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ClientOrderPlacer is implemented by clients that can hand a client order id to zb.
+type ClientOrderPlacer interface {
+	PlaceOrderWithClientId(symbol string, price, amount float64, tradeType TradeType, clientOrderId string, accessKey, secretKey string) (uint64, error)
+	GetOrderByClientId(symbol string, clientOrderId string, accessKey, secretKey string) (Order, error)
+}
+
+var _ ClientOrderPlacer = (*RestClient)(nil)
+
+type JournalStatus uint8
+
+const (
+	// JournalSubmitted orders were sent but it is not known yet whether zb has them
+	JournalSubmitted JournalStatus = iota
+	JournalPlaced
+	// JournalFailed orders were refused by zb or never reached it
+	JournalFailed
+)
+
+// JournalEntry is what an OrderJournal knows about one client order id. Time is when
+// the order was submitted, in milliseconds, and Id the id zb gave it once known.
+type JournalEntry struct {
+	ClientId  string        `json:"client_id"`
+	Symbol    string        `json:"symbol"`
+	Price     float64       `json:"price"`
+	Amount    float64       `json:"amount"`
+	TradeType TradeType     `json:"trade_type"`
+	Time      uint64        `json:"time"`
+	Id        uint64        `json:"id,omitempty"`
+	Status    JournalStatus `json:"status"`
+}
+
+// OrderJournal is an append only file of JournalEntry, one json object per line. The
+// last line of a client order id wins.
+type OrderJournal struct {
+	mu      sync.Mutex
+	file    *os.File
+	entries map[string]JournalEntry
+}
+
+func OpenOrderJournal(path string) (*OrderJournal, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &OrderJournal{file: file, entries: map[string]JournalEntry{}}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry JournalEntry
+		// a line torn by a crash is skipped, the order is then unknown to the journal
+		if stdjson.Unmarshal(scanner.Bytes(), &entry) == nil && entry.ClientId != "" {
+			j.entries[entry.ClientId] = entry
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *OrderJournal) Get(clientId string) (JournalEntry, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entry, ok := j.entries[clientId]
+	return entry, ok
+}
+
+// Record appends entry and syncs the file before returning.
+func (j *OrderJournal) Record(entry JournalEntry) error {
+	bytes, err := stdjson.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.file.Write(append(bytes, '\n')); err != nil {
+		return err
+	}
+	if err := j.file.Sync(); err != nil {
+		return err
+	}
+	j.entries[entry.ClientId] = entry
+	return nil
+}
+
+// Unresolved returns the entries still JournalSubmitted, oldest first.
+func (j *OrderJournal) Unresolved() []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var entries []JournalEntry
+	for _, entry := range j.entries {
+		if entry.Status == JournalSubmitted {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Time < entries[j].Time })
+	return entries
+}
+
+func (j *OrderJournal) claimed() map[uint64]bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	ids := map[uint64]bool{}
+	for _, entry := range j.entries {
+		if entry.Status == JournalPlaced {
+			ids[entry.Id] = true
+		}
+	}
+	return ids
+}
+
+func (j *OrderJournal) Close() error {
+	return j.file.Close()
+}
+
+var ErrOrderUnresolved = errors.New("Order was submitted but is not found on zb yet, retry after the reconciliation window")
+
+const defaultReconcileWindow = time.Minute
+
+// ClientOrders places orders under caller chosen client order ids, at most once per id.
+// Every submission is journaled before it is sent. When the outcome of a submission is
+// unknown, e.g. its response was lost, the order is looked up by client order id where
+// the Trading supports it, and otherwise among the orders of GetOrders by symbol, side,
+// price, amount and time.
+type ClientOrders struct {
+	trading   Trading
+	journal   *OrderJournal
+	accessKey string
+	secretKey string
+	window    time.Duration
+}
+
+func NewClientOrders(trading Trading, journal *OrderJournal, accessKey, secretKey string) *ClientOrders {
+	return &ClientOrders{trading: trading, journal: journal, accessKey: accessKey, secretKey: secretKey, window: defaultReconcileWindow}
+}
+
+// SetWindow sets how far, either way, the time of an order on zb may be from its
+// submission to still match, and how long an unfound order stays unresolved before it
+// is taken as lost. It defaults to one minute.
+func (c *ClientOrders) SetWindow(window time.Duration) {
+	c.window = window
+}
+
+// Place returns the id of the order already placed under clientId, if any. Otherwise it
+// submits the order. A submission whose outcome is unknown, after a network error or a
+// GeneralError such as an unreadable response, is reconciled before anything is sent
+// again: if the order is not found, Place returns ErrOrderUnresolved until the window
+// has passed and only then submits it anew.
+func (c *ClientOrders) Place(ctx context.Context, clientId string, symbol string, price, amount float64, tradeType TradeType) (uint64, error) {
+	if entry, ok := c.journal.Get(clientId); ok {
+		if entry.Status == JournalSubmitted {
+			if _, _, err := c.Reconcile(ctx, clientId); err != nil {
+				return 0, err
+			}
+			entry, _ = c.journal.Get(clientId)
+		}
+		switch entry.Status {
+		case JournalPlaced:
+			return entry.Id, nil
+		case JournalSubmitted:
+			return 0, ErrOrderUnresolved
+		}
+	}
+
+	entry := JournalEntry{ClientId: clientId, Symbol: symbol, Price: price, Amount: amount, TradeType: tradeType, Time: nowMillis(), Status: JournalSubmitted}
+	if err := c.journal.Record(entry); err != nil {
+		return 0, err
+	}
+
+	var id uint64
+	var err error
+	if placer, ok := c.trading.(ClientOrderPlacer); ok {
+		id, err = placer.PlaceOrderWithClientId(symbol, price, amount, tradeType, clientId, c.accessKey, c.secretKey)
+	} else {
+		id, err = c.trading.PlaceOrder(symbol, price, amount, tradeType, c.accessKey, c.secretKey)
+	}
+
+	if err == nil {
+		entry.Id, entry.Status = id, JournalPlaced
+		return id, c.journal.Record(entry)
+	}
+	// a GeneralError may be an unreadable response to an order that was placed
+	if apiError, ok := err.(*ApiError); ok && apiError.Code != GeneralError {
+		entry.Status = JournalFailed
+		if jerr := c.journal.Record(entry); jerr != nil {
+			return 0, jerr
+		}
+		return 0, err
+	}
+
+	if id, found, rerr := c.Reconcile(ctx, clientId); rerr == nil && found {
+		return id, nil
+	}
+	return 0, err
+}
+
+// Reconcile looks for the order of a submitted client order id on zb and journals the
+// outcome. It reports false while the order is not found.
+func (c *ClientOrders) Reconcile(ctx context.Context, clientId string) (uint64, bool, error) {
+	entry, ok := c.journal.Get(clientId)
+	if !ok {
+		return 0, false, &ApiError{Code: OrderNotFound, Message: "Unknown client order id: " + clientId}
+	}
+	if entry.Status != JournalSubmitted {
+		return entry.Id, entry.Status == JournalPlaced, nil
+	}
+
+	order, found, err := c.find(ctx, entry)
+	if err != nil {
+		return 0, false, err
+	}
+	if found {
+		entry.Id, entry.Status = order.Id, JournalPlaced
+		return order.Id, true, c.journal.Record(entry)
+	}
+	if nowMillis() > entry.Time+c.windowMillis() {
+		entry.Status = JournalFailed
+		return 0, false, c.journal.Record(entry)
+	}
+	return 0, false, nil
+}
+
+// ReconcileAll reconciles every unresolved entry of the journal, e.g. after a restart.
+func (c *ClientOrders) ReconcileAll(ctx context.Context) error {
+	for _, entry := range c.journal.Unresolved() {
+		if _, _, err := c.Reconcile(ctx, entry.ClientId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *ClientOrders) find(ctx context.Context, entry JournalEntry) (Order, bool, error) {
+	if placer, ok := c.trading.(ClientOrderPlacer); ok {
+		order, err := placer.GetOrderByClientId(entry.Symbol, entry.ClientId, c.accessKey, c.secretKey)
+		if apiError, ok := err.(*ApiError); ok && apiError.Code == OrderNotFound {
+			return Order{}, false, nil
+		}
+		return order, err == nil, err
+	}
+
+	window := c.windowMillis()
+	claimed := c.journal.claimed()
+	var best Order
+	found := false
+	filter := OrderFilter{}
+	if entry.Time > window {
+		filter.Since = entry.Time - window
+	}
+	it := NewOrderIterator(ctx, c.trading, entry.Symbol, entry.TradeType, filter, c.accessKey, c.secretKey)
+	for it.Next() {
+		order := it.Order()
+		if order.Time > entry.Time+window || claimed[order.Id] || order.TradeType != entry.TradeType ||
+			math.Abs(order.Price-entry.Price) > epsilon || math.Abs(order.TotalAmount-entry.Amount) > epsilon {
+			continue
+		}
+		if !found || c.distance(order, entry) < c.distance(best, entry) {
+			best, found = order, true
+		}
+	}
+	return best, found, it.Err()
+}
+
+func (c *ClientOrders) windowMillis() uint64 {
+	return uint64(c.window / time.Millisecond)
+}
+
+// distance ranks candidates for entry. Orders created before the submission only
+// match through clock skew, so they rank behind any created after it.
+func (c *ClientOrders) distance(order Order, entry JournalEntry) uint64 {
+	if order.Time >= entry.Time {
+		return order.Time - entry.Time
+	}
+	return entry.Time - order.Time + c.windowMillis()
+}
@@ -0,0 +1,41 @@
+package zb_test
+
+import (
+	"context"
+	"github.com/berryland/zb"
+	"github.com/berryland/zb/zbtest"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClientOrdersOffline_Malformed(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "journal")
+	defer os.RemoveAll(dir)
+	journal, _ := zb.OpenOrderJournal(filepath.Join(dir, "orders.jsonl"))
+	defer journal.Close()
+
+	s := zbtest.NewServer()
+	defer s.Close()
+	c := zb.NewClientOrders(s.RestClient(), journal, zbtest.AccessKey, zbtest.SecretKey)
+	c.SetWindow(50 * time.Millisecond)
+
+	// an unreadable response leaves the submission unresolved instead of failed
+	s.InjectMalformed("order")
+	_, err := c.Place(context.Background(), "a", "btc_usdt", 10000, 0.1, zb.Buy)
+	assert.Equal(t, zb.GeneralError, err.(*zb.ApiError).Code)
+	entry, _ := journal.Get("a")
+	assert.Equal(t, zb.JournalSubmitted, entry.Status)
+	_, err = c.Place(context.Background(), "a", "btc_usdt", 10000, 0.1, zb.Buy)
+	assert.Equal(t, zb.ErrOrderUnresolved, err)
+	assert.Equal(t, 1, s.Requests("order"))
+
+	time.Sleep(60 * time.Millisecond)
+	id, err := c.Place(context.Background(), "a", "btc_usdt", 10000, 0.1, zb.Buy)
+	assert.Nil(t, err)
+	assert.NotZero(t, id)
+	assert.Equal(t, 2, s.Requests("order"))
+}
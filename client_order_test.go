@@ -0,0 +1,82 @@
+package zb
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// lossyTrading loses the response of every PlaceOrder, after placing the order when place is set.
+type lossyTrading struct {
+	*PaperClient
+	place bool
+}
+
+func (t *lossyTrading) PlaceOrder(symbol string, price, amount float64, tradeType TradeType, accessKey, secretKey string) (uint64, error) {
+	if t.place {
+		t.PaperClient.PlaceOrder(symbol, price, amount, tradeType, accessKey, secretKey)
+	}
+	return 0, errors.New("connection reset by peer")
+}
+
+func TestClientOrders_Place(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "journal")
+	defer os.RemoveAll(dir)
+	journal, _ := OpenOrderJournal(filepath.Join(dir, "orders.jsonl"))
+	defer journal.Close()
+
+	paper := NewPaperClient(map[string]float64{"usdt": 10000}, 0)
+	other, _ := paper.PlaceOrder("btc_usdt", 9000, 0.1, Buy, "", "")
+	// identical orders are told apart by time, which zb has in milliseconds
+	time.Sleep(2 * time.Millisecond)
+	c := NewClientOrders(&lossyTrading{PaperClient: paper, place: true}, journal, "", "")
+
+	id, err := c.Place(context.Background(), "a", "btc_usdt", 9000, 0.1, Buy)
+	assert.Nil(t, err)
+	assert.NotEqual(t, other, id)
+	again, err := c.Place(context.Background(), "a", "btc_usdt", 9000, 0.1, Buy)
+	assert.Nil(t, err)
+	assert.Equal(t, id, again)
+
+	orders, _ := paper.GetOrders("btc_usdt", All, 1, 10, "", "")
+	assert.Len(t, orders, 2)
+	entry, _ := journal.Get("a")
+	assert.Equal(t, JournalPlaced, entry.Status)
+}
+
+func TestClientOrders_Unresolved(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "journal")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "orders.jsonl")
+	journal, _ := OpenOrderJournal(path)
+
+	paper := NewPaperClient(map[string]float64{"btc": 1}, 0)
+	lossy := &lossyTrading{PaperClient: paper}
+	c := NewClientOrders(lossy, journal, "", "")
+	c.SetWindow(50 * time.Millisecond)
+
+	_, err := c.Place(context.Background(), "b", "btc_usdt", 11000, 0.5, Sell)
+	assert.EqualError(t, err, "connection reset by peer")
+	_, err = c.Place(context.Background(), "b", "btc_usdt", 11000, 0.5, Sell)
+	assert.Equal(t, ErrOrderUnresolved, err)
+
+	// a restart still knows the submission is unresolved
+	journal.Close()
+	journal, _ = OpenOrderJournal(path)
+	defer journal.Close()
+	assert.Len(t, journal.Unresolved(), 1)
+	c = NewClientOrders(paper, journal, "", "")
+	c.SetWindow(50 * time.Millisecond)
+
+	time.Sleep(60 * time.Millisecond)
+	id, err := c.Place(context.Background(), "b", "btc_usdt", 11000, 0.5, Sell)
+	assert.Nil(t, err)
+	order, _ := paper.GetOrder("btc_usdt", id, "", "")
+	assert.Equal(t, 0.5, order.TotalAmount)
+	assert.Empty(t, journal.Unresolved())
+}
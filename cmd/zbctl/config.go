@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+type credentials struct {
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+}
+
+func defaultConfigPath() string {
+	home := os.Getenv("HOME")
+	if home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".zbctl.json")
+}
+
+// loadCredentials prefers the environment and falls back to the config file.
+func loadCredentials(path string) (credentials, error) {
+	c := credentials{AccessKey: os.Getenv("ZB_ACCESS_KEY"), SecretKey: os.Getenv("ZB_SECRET_KEY")}
+	if c.AccessKey != "" && c.SecretKey != "" {
+		return c, nil
+	}
+
+	if path == "" {
+		return c, errors.New("no credentials, set ZB_ACCESS_KEY and ZB_SECRET_KEY or use -config")
+	}
+	bytes, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, fmt.Errorf("no credentials, set ZB_ACCESS_KEY and ZB_SECRET_KEY or create %s", path)
+	}
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(bytes, &c); err != nil {
+		return c, fmt.Errorf("invalid config %s: %v", path, err)
+	}
+	if c.AccessKey == "" || c.SecretKey == "" {
+		return c, fmt.Errorf("config %s lacks access_key or secret_key", path)
+	}
+	return c, nil
+}
+
+// dryRunTransport prints each request instead of sending it and answers with an
+// empty json object, which every RestClient method accepts as a success.
+type dryRunTransport struct {
+	writer io.Writer
+}
+
+func (t dryRunTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	fmt.Fprintf(t.writer, "%s %s\n", r.Method, r.URL.String())
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       ioutil.NopCloser(bytes.NewBufferString("{}")),
+		Request:    r,
+	}, nil
+}
+
+func dryRunClient(w io.Writer) *http.Client {
+	return &http.Client{Transport: dryRunTransport{writer: w}}
+}
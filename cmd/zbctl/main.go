@@ -0,0 +1,419 @@
+// zbctl queries zb.com market data and manages an account from the command line.
+//
+//	zbctl [-o table|json|csv] [-config file] [-dry-run] <command> [arguments]
+//
+// Credentials are read from ZB_ACCESS_KEY and ZB_SECRET_KEY, or from a json config
+// file holding access_key and secret_key, ~/.zbctl.json by default.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/berryland/zb"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const usage = `Usage: zbctl [flags] <command> [arguments]
+
+Commands:
+  symbols
+  ticker <symbol>
+  depth <symbol> [size]
+  trades <symbol> [since]
+  klines <symbol> <period> [since] [size]
+  account
+  orders <symbol> [all|buy|sell] [page] [size]
+  order place <symbol> <buy|sell> <price> <amount>
+  order cancel <symbol> <id>
+  order get <symbol> <id>
+  watch ticker <symbol>
+
+Flags:
+`
+
+type cli struct {
+	client      *zb.RestClient
+	dial        func() *zb.WebSocketClient
+	credentials credentials
+	printer     *printer
+}
+
+func main() {
+	flags := flag.NewFlagSet("zbctl", flag.ExitOnError)
+	output := flags.String("o", "table", "output format: table, json or csv")
+	config := flags.String("config", defaultConfigPath(), "json file with access_key and secret_key")
+	dryRun := flags.Bool("dry-run", false, "print requests, signed when needed, instead of sending them")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+
+	p, err := newPrinter(*output, os.Stdout)
+	if err != nil {
+		fail(err)
+	}
+	c := &cli{client: zb.NewRestClient(), dial: zb.NewWebSocketClient, printer: p}
+	if *dryRun {
+		c.client.SetHttpClient(dryRunClient(os.Stdout))
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if needsCredentials(flags.Args()) {
+		c.credentials, err = loadCredentials(*config)
+		if err != nil {
+			fail(err)
+		}
+	}
+	if err := c.run(flags.Args()); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "zbctl: "+err.Error())
+	os.Exit(1)
+}
+
+func needsCredentials(args []string) bool {
+	switch args[0] {
+	case "account", "orders", "order":
+		return true
+	}
+	return false
+}
+
+func (c *cli) run(args []string) error {
+	command, args := args[0], args[1:]
+	switch command {
+	case "symbols":
+		return c.symbols()
+	case "ticker":
+		return c.ticker(args)
+	case "depth":
+		return c.depth(args)
+	case "trades":
+		return c.trades(args)
+	case "klines":
+		return c.klines(args)
+	case "account":
+		return c.account()
+	case "orders":
+		return c.orders(args)
+	case "order":
+		return c.order(args)
+	case "watch":
+		return c.watch(args)
+	}
+	return fmt.Errorf("unknown command %q", command)
+}
+
+func (c *cli) symbols() error {
+	symbols, err := c.client.GetSymbols()
+	if err != nil {
+		return err
+	}
+
+	t := newTable(symbols, "symbol", "amount_scale", "price_scale")
+	for _, symbol := range sortedSymbols(symbols) {
+		config := symbols[symbol]
+		t.add(symbol, config.AmountScale, config.PriceScale)
+	}
+	return c.printer.print(t)
+}
+
+func sortedSymbols(symbols map[string]zb.SymbolConfig) []string {
+	var keys []string
+	for symbol := range symbols {
+		keys = append(keys, symbol)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (c *cli) ticker(args []string) error {
+	if len(args) != 1 {
+		return usageError("ticker <symbol>")
+	}
+	quote, err := c.client.GetLatestQuote(args[0])
+	if err != nil {
+		return err
+	}
+	return c.printer.print(quoteTable(quote))
+}
+
+func quoteTable(quote zb.Quote) *table {
+	t := newTable(quote, "time", "last", "buy", "sell", "high", "low", "volume")
+	t.add(quote.Time, quote.Last, quote.Buy, quote.Sell, quote.High, quote.Low, quote.Volume)
+	return t
+}
+
+func (c *cli) depth(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return usageError("depth <symbol> [size]")
+	}
+	size, err := optionalUint(args, 1, 10, 8)
+	if err != nil {
+		return err
+	}
+	depth, err := c.client.GetDepth(args[0], uint8(size))
+	if err != nil {
+		return err
+	}
+
+	t := newTable(depth, "side", "price", "volume")
+	for _, e := range depth.Asks {
+		t.add("ask", e.Price, e.Volume)
+	}
+	for _, e := range depth.Bids {
+		t.add("bid", e.Price, e.Volume)
+	}
+	return c.printer.print(t)
+}
+
+func (c *cli) trades(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return usageError("trades <symbol> [since]")
+	}
+	since, err := optionalUint(args, 1, 0, 64)
+	if err != nil {
+		return err
+	}
+	trades, err := c.client.GetTrades(args[0], since)
+	if err != nil {
+		return err
+	}
+
+	t := newTable(trades, "tid", "time", "type", "price", "amount")
+	for _, trade := range trades {
+		t.add(trade.Id, trade.Time, tradeTypeName(trade.TradeType), trade.Price, trade.Amount)
+	}
+	return c.printer.print(t)
+}
+
+func (c *cli) klines(args []string) error {
+	if len(args) < 2 || len(args) > 4 {
+		return usageError("klines <symbol> <period> [since] [size]")
+	}
+	since, err := optionalUint(args, 2, 0, 64)
+	if err != nil {
+		return err
+	}
+	size, err := optionalUint(args, 3, 100, 16)
+	if err != nil {
+		return err
+	}
+	klines, err := c.client.GetKlines(args[0], args[1], since, uint16(size))
+	if err != nil {
+		return err
+	}
+
+	t := newTable(klines, "time", "open", "high", "low", "close", "volume")
+	for _, k := range klines {
+		t.add(k.Time, k.Open, k.High, k.Low, k.Close, k.Volume)
+	}
+	return c.printer.print(t)
+}
+
+func (c *cli) account() error {
+	account, err := c.client.GetAccount(c.credentials.AccessKey, c.credentials.SecretKey)
+	if err != nil {
+		return err
+	}
+
+	t := newTable(account, "coin", "available", "freeze", "total")
+	for _, asset := range account.Assets {
+		t.add(asset.Coin.Key, asset.Available, asset.Freeze, asset.Total())
+	}
+	return c.printer.print(t)
+}
+
+func (c *cli) orders(args []string) error {
+	if len(args) < 1 || len(args) > 4 {
+		return usageError("orders <symbol> [all|buy|sell] [page] [size]")
+	}
+	tradeType := zb.All
+	if len(args) > 1 {
+		var err error
+		if tradeType, err = parseTradeType(args[1], true); err != nil {
+			return err
+		}
+	}
+	page, err := optionalUint(args, 2, 1, 64)
+	if err != nil {
+		return err
+	}
+	size, err := optionalUint(args, 3, 10, 16)
+	if err != nil {
+		return err
+	}
+
+	orders, err := c.client.GetOrders(args[0], tradeType, page, uint16(size), c.credentials.AccessKey, c.credentials.SecretKey)
+	if apiError, ok := err.(*zb.ApiError); ok && apiError.Code == zb.OrderNotFound {
+		orders, err = []zb.Order{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	return c.printer.print(ordersTable(orders, orders...))
+}
+
+func ordersTable(value interface{}, orders ...zb.Order) *table {
+	t := newTable(value, "id", "time", "symbol", "type", "status", "price", "amount", "filled", "average")
+	for _, o := range orders {
+		t.add(o.Id, o.Time, o.Symbol, tradeTypeName(o.TradeType), statusName(o.Status), o.Price, o.TotalAmount, o.TradeAmount, o.Average)
+	}
+	return t
+}
+
+func (c *cli) order(args []string) error {
+	if len(args) == 0 {
+		return usageError("order place|cancel|get ...")
+	}
+	ak, sk := c.credentials.AccessKey, c.credentials.SecretKey
+
+	switch args[0] {
+	case "place":
+		if len(args) != 5 {
+			return usageError("order place <symbol> <buy|sell> <price> <amount>")
+		}
+		tradeType, err := parseTradeType(args[2], false)
+		if err != nil {
+			return err
+		}
+		price, err := strconv.ParseFloat(args[3], 64)
+		if err != nil {
+			return fmt.Errorf("invalid price %q", args[3])
+		}
+		amount, err := strconv.ParseFloat(args[4], 64)
+		if err != nil {
+			return fmt.Errorf("invalid amount %q", args[4])
+		}
+		id, err := c.client.PlaceOrder(args[1], price, amount, tradeType, ak, sk)
+		if err != nil {
+			return err
+		}
+		t := newTable(map[string]uint64{"id": id}, "id")
+		t.add(id)
+		return c.printer.print(t)
+	case "cancel", "get":
+		if len(args) != 3 {
+			return usageError("order " + args[0] + " <symbol> <id>")
+		}
+		id, err := strconv.ParseUint(args[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid order id %q", args[2])
+		}
+		if args[0] == "cancel" {
+			return c.client.CancelOrder(args[1], id, ak, sk)
+		}
+		order, err := c.client.GetOrder(args[1], id, ak, sk)
+		if err != nil {
+			return err
+		}
+		return c.printer.print(ordersTable(order, order))
+	}
+	return fmt.Errorf("unknown order command %q", args[0])
+}
+
+func (c *cli) watch(args []string) error {
+	if len(args) != 2 || args[0] != "ticker" {
+		return usageError("watch ticker <symbol>")
+	}
+
+	ws := c.dial()
+	closed := make(chan error, 1)
+	ws.OnClose(func(err error) {
+		if err == nil {
+			err = errors.New("connection closed")
+		}
+		closed <- err
+	})
+	if err := ws.TryConnect(); err != nil {
+		return err
+	}
+	defer ws.Disconnect()
+
+	quotes := make(chan zb.Quote, 16)
+	ws.SubscribeQuote(args[1], func(quote zb.Quote) {
+		quotes <- quote
+	})
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	header := true
+	for {
+		select {
+		case quote := <-quotes:
+			if err := c.printer.stream(quoteTable(quote), header); err != nil {
+				return err
+			}
+			header = false
+		case err := <-closed:
+			return err
+		case <-interrupt:
+			return nil
+		}
+	}
+}
+
+func usageError(command string) error {
+	return fmt.Errorf("usage: zbctl %s", command)
+}
+
+func optionalUint(args []string, i int, value uint64, bits int) (uint64, error) {
+	if len(args) <= i {
+		return value, nil
+	}
+	parsed, err := strconv.ParseUint(args[i], 10, bits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", args[i])
+	}
+	return parsed, nil
+}
+
+func parseTradeType(s string, allowAll bool) (zb.TradeType, error) {
+	switch strings.ToLower(s) {
+	case "buy":
+		return zb.Buy, nil
+	case "sell":
+		return zb.Sell, nil
+	case "all":
+		if allowAll {
+			return zb.All, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid trade type %q", s)
+}
+
+func tradeTypeName(t zb.TradeType) string {
+	switch t {
+	case zb.Buy:
+		return "buy"
+	case zb.Sell:
+		return "sell"
+	}
+	return "all"
+}
+
+func statusName(s zb.OrderStatus) string {
+	switch s {
+	case zb.Pending:
+		return "pending"
+	case zb.Cancelled:
+		return "cancelled"
+	case zb.Finished:
+		return "finished"
+	case zb.PartiallyFilled:
+		return "partially_filled"
+	}
+	return strconv.Itoa(int(s))
+}
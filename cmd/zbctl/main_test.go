@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"github.com/berryland/zb"
+	"github.com/berryland/zb/zbtest"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestCli(format string) (*cli, *bytes.Buffer, *zbtest.Server) {
+	s := zbtest.NewServer()
+	out := new(bytes.Buffer)
+	p, _ := newPrinter(format, out)
+	return &cli{client: s.RestClient(), dial: s.WebSocketClient, credentials: credentials{AccessKey: s.AccessKey, SecretKey: s.SecretKey}, printer: p}, out, s
+}
+
+func TestCli_Ticker(t *testing.T) {
+	c, out, s := newTestCli("csv")
+	defer s.Close()
+
+	assert.Nil(t, c.run([]string{"ticker", "btc_usdt"}))
+	assert.Equal(t, "time,last,buy,sell,high,low,volume\n1516029900000,11000,10999,11001,11500,10500,1200.5\n", out.String())
+}
+
+func TestCli_Watch(t *testing.T) {
+	c, out, s := newTestCli("csv")
+	defer s.Close()
+
+	done := make(chan error)
+	go func() {
+		done <- c.run([]string{"watch", "ticker", "btc_usdt"})
+	}()
+	assert.True(t, s.WaitSubscribed(zbtest.Channel("btc_usdt", "ticker"), time.Second))
+	s.PublishQuote("btc_usdt", zb.Quote{Last: 11100, Time: 1516029960000})
+	time.Sleep(50 * time.Millisecond)
+
+	// a dropped connection ends the command instead of leaving it waiting
+	s.DropConnections()
+	select {
+	case err := <-done:
+		assert.NotNil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("watch did not return")
+	}
+	assert.Contains(t, out.String(), "11100")
+}
+
+func TestCli_Order(t *testing.T) {
+	c, out, s := newTestCli("json")
+	defer s.Close()
+
+	assert.Nil(t, c.run([]string{"order", "place", "btc_usdt", "sell", "15000", "0.01"}))
+	id := s.Orders()[0].Id
+	assert.Contains(t, out.String(), `{"id":`)
+
+	out.Reset()
+	c.printer.format = "table"
+	assert.Nil(t, c.run([]string{"orders", "btc_usdt", "sell"}))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[1], "2018012200000001"))
+
+	assert.Nil(t, c.run([]string{"order", "cancel", "btc_usdt", "2018012200000001"}))
+	order, _ := s.Order(id)
+	assert.Equal(t, zb.Cancelled, order.Status)
+
+	assert.NotNil(t, c.run([]string{"order", "place", "btc_usdt", "hold", "1", "1"}))
+}
+
+func TestCli_DryRun(t *testing.T) {
+	c, out, s := newTestCli("table")
+	defer s.Close()
+	c.client.SetHttpClient(dryRunClient(out))
+
+	assert.Nil(t, c.run([]string{"order", "cancel", "btc_usdt", "42"}))
+	assert.Contains(t, out.String(), "GET "+s.TradeApiUrl()+"cancelOrder?")
+	assert.Contains(t, out.String(), "sign=")
+	assert.Equal(t, 0, s.Requests("cancelOrder"))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+// table holds the rows printed as table or csv, and the value printed as json.
+type table struct {
+	value   interface{}
+	headers []string
+	rows    [][]string
+}
+
+func newTable(value interface{}, headers ...string) *table {
+	return &table{value: value, headers: headers}
+}
+
+func (t *table) add(cells ...interface{}) {
+	row := make([]string, len(cells))
+	for i, cell := range cells {
+		switch v := cell.(type) {
+		case float64:
+			row[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			row[i] = fmt.Sprint(v)
+		}
+	}
+	t.rows = append(t.rows, row)
+}
+
+type printer struct {
+	format string
+	writer io.Writer
+}
+
+func newPrinter(format string, w io.Writer) (*printer, error) {
+	switch format {
+	case "table", "json", "csv":
+		return &printer{format: format, writer: w}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q", format)
+}
+
+func (p *printer) print(t *table) error {
+	return p.stream(t, true)
+}
+
+// stream prints t, leaving out the headers when it continues an earlier table.
+func (p *printer) stream(t *table, header bool) error {
+	switch p.format {
+	case "json":
+		bytes, err := json.Marshal(t.value)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(p.writer, string(bytes))
+		return err
+	case "csv":
+		w := csv.NewWriter(p.writer)
+		if header {
+			w.Write(t.headers)
+		}
+		w.WriteAll(t.rows)
+		return w.Error()
+	}
+
+	w := tabwriter.NewWriter(p.writer, 0, 4, 2, ' ', 0)
+	if header {
+		writeTabbed(w, t.headers)
+	}
+	for _, row := range t.rows {
+		writeTabbed(w, row)
+	}
+	return w.Flush()
+}
+
+func writeTabbed(w io.Writer, cells []string) {
+	for i, cell := range cells {
+		if i > 0 {
+			io.WriteString(w, "\t")
+		}
+		io.WriteString(w, cell)
+	}
+	io.WriteString(w, "\n")
+}
@@ -0,0 +1,65 @@
+// zbrecorder records quotes, depth, trades and klines of zb.com markets to disk until
+// interrupted.
+//
+//	zbrecorder [-dir data] [-formats csv,parquet] [-period 1min] [-rotate 1h] btc_usdt eth_usdt
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/berryland/zb"
+	"github.com/berryland/zb/recorder"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func main() {
+	flags := flag.NewFlagSet("zbrecorder", flag.ExitOnError)
+	dir := flags.String("dir", "data", "directory to write to")
+	formats := flags.String("formats", "csv", "comma separated output formats: csv, parquet")
+	period := flags.String("period", "1min", "kline period")
+	rotate := flags.Duration("rotate", time.Hour, "how long each file is written, at most 24h")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: zbrecorder [flags] <symbol>...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	config := recorder.Config{Dir: *dir, Symbols: flags.Args(), Rotate: *rotate}
+	for _, name := range strings.Split(*formats, ",") {
+		format, err := recorder.ParseFormat(strings.TrimSpace(name))
+		if err != nil {
+			fail(err)
+		}
+		config.Formats = append(config.Formats, format)
+	}
+	var err error
+	if config.Period, err = zb.ParsePeriod(*period); err != nil {
+		fail(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupt
+		cancel()
+	}()
+
+	if err := recorder.New(config, zb.NewRestClient(), zb.NewWebSocketClient).Run(ctx); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "zbrecorder: "+err.Error())
+	os.Exit(1)
+}
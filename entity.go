@@ -22,13 +22,18 @@ type Quote struct {
 
 func marshalQuote(value []byte) Quote {
 	ticker, _, _, _ := json.Get(value, "ticker")
+	timeString, _ := json.GetString(value, "date")
+	time, _ := strconv.ParseUint(timeString, 10, 64)
+	return marshalTicker(ticker, time)
+}
+
+func marshalTicker(ticker []byte, time uint64) Quote {
 	volumeString, _ := json.GetString(ticker, "vol")
 	lastString, _ := json.GetString(ticker, "last")
 	sellString, _ := json.GetString(ticker, "sell")
 	buyString, _ := json.GetString(ticker, "buy")
 	highString, _ := json.GetString(ticker, "high")
 	lowString, _ := json.GetString(ticker, "low")
-	timeString, _ := json.GetString(value, "date")
 
 	volume, _ := strconv.ParseFloat(volumeString, 64)
 	last, _ := strconv.ParseFloat(lastString, 64)
@@ -36,11 +41,30 @@ func marshalQuote(value []byte) Quote {
 	buy, _ := strconv.ParseFloat(buyString, 64)
 	high, _ := strconv.ParseFloat(highString, 64)
 	low, _ := strconv.ParseFloat(lowString, 64)
-	time, _ := strconv.ParseUint(timeString, 10, 64)
 
 	return Quote{Volume: volume, Last: last, Sell: sell, Buy: buy, High: high, Low: low, Time: time}
 }
 
+// getFloat reads a number that zb sends either as a json number or as a string.
+func getFloat(value []byte, keys ...string) float64 {
+	if f, err := json.GetFloat(value, keys...); err == nil {
+		return f
+	}
+	s, _ := json.GetString(value, keys...)
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+// getUint reads an id or a time that zb sends either as a json number or as a string.
+func getUint(value []byte, keys ...string) uint64 {
+	if i, err := json.GetInt(value, keys...); err == nil {
+		return uint64(i)
+	}
+	s, _ := json.GetString(value, keys...)
+	i, _ := strconv.ParseUint(s, 10, 64)
+	return i
+}
+
 type Kline struct {
 	Open   float64
 	Close  float64
@@ -58,6 +82,23 @@ type Trade struct {
 	Time      uint64
 }
 
+func marshalTrades(value []byte, keys ...string) []Trade {
+	var trades []Trade
+	json.ArrayEach(value, func(value []byte, dataType json.ValueType, offset int, err error) {
+		id, _ := json.GetInt(value, "tid")
+		tradeType, _ := json.GetString(value, "type")
+		amountString, _ := json.GetString(value, "amount")
+		priceString, _ := json.GetString(value, "price")
+		time, _ := json.GetInt(value, "date")
+
+		amount, _ := strconv.ParseFloat(amountString, 64)
+		price, _ := strconv.ParseFloat(priceString, 64)
+
+		trades = append(trades, Trade{Id: uint64(id), TradeType: ParseTradeType(tradeType), Price: price, Amount: amount, Time: uint64(time)})
+	}, keys...)
+	return trades
+}
+
 type TradeType int8
 
 const (
@@ -88,6 +129,12 @@ type DepthEntry struct {
 	Volume float64
 }
 
+func marshalDepth(value []byte) Depth {
+	time, _ := json.GetInt(value, "timestamp")
+	asks, bids := marshalDepthEntries(value, "asks"), marshalDepthEntries(value, "bids")
+	return Depth{Asks: asks, Bids: bids, Time: uint64(time)}
+}
+
 func marshalDepthEntries(value []byte, keys ...string) []DepthEntry {
 	var entry []DepthEntry
 	json.ArrayEach(value, func(value []byte, dataType json.ValueType, offset int, err error) {
@@ -0,0 +1,45 @@
+package zb
+
+type MarketData interface {
+	GetSymbols() (map[string]SymbolConfig, error)
+	GetLatestQuote(symbol string) (Quote, error)
+	GetAllQuotes() (map[string]Quote, error)
+	GetKlines(symbol string, period string, since uint64, size uint16) ([]Kline, error)
+	GetTrades(symbol string, since uint64) ([]Trade, error)
+	GetDepth(symbol string, size uint8) (Depth, error)
+}
+
+type Trading interface {
+	PlaceOrder(symbol string, price, amount float64, tradeType TradeType, accessKey, secretKey string) (uint64, error)
+	CancelOrder(symbol string, id uint64, accessKey, secretKey string) error
+	GetOrder(symbol string, id uint64, accessKey, secretKey string) (Order, error)
+	GetOrders(symbol string, tradeType TradeType, page uint64, size uint16, accessKey, secretKey string) ([]Order, error)
+	GetOpenOrders(symbol string, accessKey, secretKey string) ([]Order, error)
+}
+
+type AccountReader interface {
+	GetAccount(accessKey string, secretKey string) (Account, error)
+}
+
+type Streamer interface {
+	Connect()
+	Disconnect()
+	SubscribeQuote(symbol string, callback func(quote Quote))
+	SubscribeDepth(symbol string, callback func(depth Depth))
+	SubscribeTrades(symbol string, callback func(trades []Trade))
+}
+
+// Exchange is everything RestClient offers, for code that needs market data and trading at once.
+type Exchange interface {
+	MarketData
+	Trading
+	AccountReader
+}
+
+var (
+	_ Exchange      = (*RestClient)(nil)
+	_ Trading       = (*PaperClient)(nil)
+	_ AccountReader = (*PaperClient)(nil)
+	_ Trading       = (*RiskGuard)(nil)
+	_ Streamer      = (*WebSocketClient)(nil)
+)
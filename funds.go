@@ -0,0 +1,182 @@
+package zb
+
+import (
+	json "github.com/buger/jsonparser"
+	"strconv"
+)
+
+type WithdrawStatus uint8
+
+const (
+	WithdrawPending WithdrawStatus = iota
+	WithdrawFailed
+	WithdrawSucceeded
+	WithdrawCancelled
+)
+
+type DepositStatus uint8
+
+const (
+	DepositConfirming DepositStatus = iota
+	DepositFailed
+	DepositSucceeded
+)
+
+type Withdrawal struct {
+	Id         uint64
+	Amount     float64
+	Fees       float64
+	Address    string
+	Status     WithdrawStatus
+	SubmitTime uint64
+	ManageTime uint64
+}
+
+type Deposit struct {
+	Id            uint64
+	Coin          string
+	Address       string
+	Amount        float64
+	Confirmations uint64
+	Hash          string
+	Description   string
+	Status        DepositStatus
+	Time          uint64
+}
+
+type WithdrawFee struct {
+	Coin        string
+	Chain       string
+	Fee         float64
+	CanDeposit  bool
+	CanWithdraw bool
+}
+
+// GetDepositAddress returns the address to deposit coin to.
+func (c *RestClient) GetDepositAddress(coin string, accessKey, secretKey string) (string, error) {
+	bytes, err := c.doTrade("getUserAddress", map[string]string{"currency": coin}, accessKey, secretKey)
+	if err != nil {
+		return "", err
+	}
+	address, _ := json.GetString(bytes, "message", "datas", "key")
+	return address, nil
+}
+
+// GetWithdrawAddress returns the whitelisted address withdrawals of coin are sent to.
+func (c *RestClient) GetWithdrawAddress(coin string, accessKey, secretKey string) (string, error) {
+	bytes, err := c.doTrade("getWithdrawAddress", map[string]string{"currency": coin}, accessKey, secretKey)
+	if err != nil {
+		return "", err
+	}
+	address, _ := json.GetString(bytes, "message", "datas", "key")
+	return address, nil
+}
+
+func (c *RestClient) GetWithdrawals(coin string, page uint64, size uint16, accessKey, secretKey string) ([]Withdrawal, error) {
+	q := map[string]string{
+		"currency":  coin,
+		"pageIndex": strconv.FormatUint(page, 10),
+		"pageSize":  strconv.FormatUint(uint64(size), 10),
+	}
+	bytes, err := c.doTrade("getWithdrawRecord", q, accessKey, secretKey)
+	if err != nil {
+		return []Withdrawal{}, err
+	}
+
+	var withdrawals []Withdrawal
+	json.ArrayEach(bytes, func(value []byte, dataType json.ValueType, offset int, err error) {
+		address, _ := json.GetString(value, "toAddress")
+		status, _ := json.GetInt(value, "status")
+		withdrawals = append(withdrawals, Withdrawal{
+			Id:         getUint(value, "id"),
+			Amount:     getFloat(value, "amount"),
+			Fees:       getFloat(value, "fees"),
+			Address:    address,
+			Status:     WithdrawStatus(status),
+			SubmitTime: getUint(value, "submitTime"),
+			ManageTime: getUint(value, "manageTime"),
+		})
+	}, "message", "datas", "list")
+	return withdrawals, nil
+}
+
+func (c *RestClient) GetDeposits(coin string, page uint64, size uint16, accessKey, secretKey string) ([]Deposit, error) {
+	q := map[string]string{
+		"currency":  coin,
+		"pageIndex": strconv.FormatUint(page, 10),
+		"pageSize":  strconv.FormatUint(uint64(size), 10),
+	}
+	bytes, err := c.doTrade("getChargeRecord", q, accessKey, secretKey)
+	if err != nil {
+		return []Deposit{}, err
+	}
+
+	var deposits []Deposit
+	json.ArrayEach(bytes, func(value []byte, dataType json.ValueType, offset int, err error) {
+		currency, _ := json.GetString(value, "currency")
+		address, _ := json.GetString(value, "address")
+		hash, _ := json.GetString(value, "hash")
+		description, _ := json.GetString(value, "description")
+		status, _ := json.GetInt(value, "status")
+		deposits = append(deposits, Deposit{
+			Id:            getUint(value, "id"),
+			Coin:          currency,
+			Address:       address,
+			Amount:        getFloat(value, "amount"),
+			Confirmations: getUint(value, "confirmTimes"),
+			Hash:          hash,
+			Description:   description,
+			Status:        DepositStatus(status),
+			Time:          getUint(value, "submitTime"),
+		})
+	}, "message", "datas", "list")
+	return deposits, nil
+}
+
+// GetWithdrawFees returns the withdrawal fee of coin on every chain it can be sent on.
+func (c *RestClient) GetWithdrawFees(coin string, accessKey, secretKey string) ([]WithdrawFee, error) {
+	bytes, err := c.doTrade("getFeeInfo", map[string]string{"currency": coin}, accessKey, secretKey)
+	if err != nil {
+		return []WithdrawFee{}, err
+	}
+
+	var fees []WithdrawFee
+	json.ArrayEach(bytes, func(value []byte, dataType json.ValueType, offset int, err error) {
+		chain, _ := json.GetString(value, "chainName")
+		canDeposit, _ := json.GetBoolean(value, "canDeposit")
+		canWithdraw, _ := json.GetBoolean(value, "canWithdraw")
+		fees = append(fees, WithdrawFee{Coin: coin, Chain: chain, Fee: getFloat(value, "fee"), CanDeposit: canDeposit, CanWithdraw: canWithdraw})
+	}, "result")
+	return fees, nil
+}
+
+// Withdraw sends amount of coin to address, internal transfers to other zb users
+// skip the blockchain. fees must match one of GetWithdrawFees.
+func (c *RestClient) Withdraw(coin string, amount, fees float64, address string, internal bool, safePassword string, accessKey, secretKey string) (uint64, error) {
+	q := map[string]string{
+		"currency":    coin,
+		"amount":      strconv.FormatFloat(amount, 'f', -1, 64),
+		"fees":        strconv.FormatFloat(fees, 'f', -1, 64),
+		"receiveAddr": address,
+		"itransfer":   "0",
+		"safePwd":     safePassword,
+	}
+	if internal {
+		q["itransfer"] = "1"
+	}
+	bytes, err := c.doTrade("withdraw", q, accessKey, secretKey)
+	if err != nil {
+		return 0, err
+	}
+	return getUint(bytes, "id"), nil
+}
+
+func (c *RestClient) CancelWithdraw(coin string, id uint64, safePassword string, accessKey, secretKey string) error {
+	q := map[string]string{
+		"currency":   coin,
+		"downloadId": strconv.FormatUint(id, 10),
+		"safePwd":    safePassword,
+	}
+	_, err := c.doTrade("cancelWithdraw", q, accessKey, secretKey)
+	return err
+}
@@ -0,0 +1,75 @@
+package zb_test
+
+import (
+	"github.com/berryland/zb"
+	"github.com/berryland/zb/zbtest"
+	"github.com/stretchr/testify/assert"
+	"net/url"
+	"testing"
+)
+
+func TestRestClientOffline_GetDepositAddress(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	s.Handle("getUserAddress", func(params url.Values) string {
+		return `{"code":1000,"message":{"des":"success","isSuc":true,"datas":{"key":"` + params.Get("currency") + `-address"}}}`
+	})
+
+	address, err := s.RestClient().GetDepositAddress("btc", s.AccessKey, s.SecretKey)
+	assert.Nil(t, err)
+	assert.Equal(t, "btc-address", address)
+
+	s.Respond("getWithdrawAddress", `{"code":1003,"message":{"des":"auth failed","isSuc":false,"datas":{}}}`)
+	_, err = s.RestClient().GetWithdrawAddress("btc", s.AccessKey, s.SecretKey)
+	assert.Equal(t, &zb.ApiError{Code: zb.AuthenticationFailed, Message: "auth failed"}, err)
+}
+
+func TestRestClientOffline_GetWithdrawals(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	s.Respond("getWithdrawRecord", `{"code":1000,"message":{"des":"success","isSuc":true,"datas":{"list":[
+		{"amount":0.01,"fees":0.001,"id":2016042556231,"manageTime":1461579340000,"status":3,"submitTime":1461579288000,"toAddress":"14fxEPirL9fyfw1i9EF439Pq6gQ5xijUmp"}
+	],"pageIndex":1,"pageSize":10,"totalCount":1,"totalPage":1}}}`)
+
+	withdrawals, err := s.RestClient().GetWithdrawals("btc", 1, 10, s.AccessKey, s.SecretKey)
+	assert.Nil(t, err)
+	assert.Equal(t, []zb.Withdrawal{{Id: 2016042556231, Amount: 0.01, Fees: 0.001, Address: "14fxEPirL9fyfw1i9EF439Pq6gQ5xijUmp", Status: zb.WithdrawCancelled, SubmitTime: 1461579288000, ManageTime: 1461579340000}}, withdrawals)
+}
+
+func TestRestClientOffline_GetDeposits(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	s.Respond("getChargeRecord", `{"code":1000,"message":{"des":"success","isSuc":true,"datas":{"list":[
+		{"address":"1FKN1DZqCm8HaTujDioRL2Aezdh7Qj7xxx","amount":"1.00000000","confirmTimes":1,"currency":"BTC","description":"confirmed","hash":"7ce842de187c379abafadd64a5fe66c5c61c8a21fb04edff9532234a1dae6xxx","id":558,"itransfer":1,"status":2,"submit_time":"2016-12-07 18:51:57","submitTime":1481107917000}
+	],"pageIndex":1,"pageSize":10,"total":1}}}`)
+
+	deposits, err := s.RestClient().GetDeposits("btc", 1, 10, s.AccessKey, s.SecretKey)
+	assert.Nil(t, err)
+	assert.Len(t, deposits, 1)
+	assert.Equal(t, 1.0, deposits[0].Amount)
+	assert.Equal(t, zb.DepositSucceeded, deposits[0].Status)
+	assert.Equal(t, uint64(1481107917000), deposits[0].Time)
+}
+
+func TestRestClientOffline_Withdraw(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	s.Respond("getFeeInfo", `{"code":1000,"message":"success","result":[{"chainName":"ERC20","canDeposit":true,"canWithdraw":true,"fee":3.5}]}`)
+	s.Handle("withdraw", func(params url.Values) string {
+		assert.Equal(t, "3.5", params.Get("fees"))
+		assert.Equal(t, "0", params.Get("itransfer"))
+		return `{"code":1000,"message":"success","id":"1234"}`
+	})
+
+	c := s.RestClient()
+	fees, err := c.GetWithdrawFees("usdt", s.AccessKey, s.SecretKey)
+	assert.Nil(t, err)
+	assert.Equal(t, []zb.WithdrawFee{{Coin: "usdt", Chain: "ERC20", Fee: 3.5, CanDeposit: true, CanWithdraw: true}}, fees)
+
+	id, err := c.Withdraw("usdt", 100, fees[0].Fee, "0xabc", false, "safe", s.AccessKey, s.SecretKey)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1234), id)
+
+	s.InjectError("cancelWithdraw", zb.GeneralError)
+	assert.NotNil(t, c.CancelWithdraw("usdt", id, "safe", s.AccessKey, s.SecretKey))
+}
@@ -0,0 +1,413 @@
+// Package grid keeps a ladder of buy and sell limit orders around the mid price of a
+// market. A filled buy is replaced by a sell one level higher and a filled sell by a buy
+// one level lower, so the ladder keeps one free level where the price last traded. The
+// ladder is rebuilt around the new mid price once the market moves away too far.
+package grid
+
+import (
+	"context"
+	"errors"
+	"github.com/berryland/zb"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPollInterval = time.Second
+	epsilon             = 1e-9
+)
+
+type Config struct {
+	Symbol string
+	// Levels is the number of orders on either side
+	Levels int
+	// Spacing is the price ratio between neighbouring levels minus one, e.g. 0.005
+	Spacing float64
+	// Amount of every order, in the base coin
+	Amount float64
+	// Requote is how far, as a fraction, the mid price may move from the center of the
+	// ladder before it is rebuilt, 0 to never rebuild
+	Requote float64
+	// MaxBase caps the base coin balance buys may lead to, open buys included, 0 for no cap
+	MaxBase float64
+	// MinBase is the base coin balance sells may not go below, open sells included
+	MinBase      float64
+	PollInterval time.Duration
+}
+
+// Level is one rung of the ladder. Slot counts levels from the center, negative below
+// it, and Id is 0 while no order is working at the level.
+type Level struct {
+	Slot      int
+	Price     float64
+	TradeType zb.TradeType
+	Id        uint64
+}
+
+// Grid runs the ladder of one market.
+type Grid struct {
+	trading   zb.Trading
+	market    zb.MarketData
+	account   zb.AccountReader
+	config    Config
+	accessKey string
+	secretKey string
+	base      string
+	scales    zb.SymbolConfig
+
+	mu     sync.Mutex
+	depth  *zb.Depth
+	center float64
+	levels map[int]*Level
+	// unknown holds the slots whose order may have reached zb without its id coming back
+	unknown map[int]bool
+	fills   []zb.Order
+}
+
+// New creates a grid trading with trading, reading scales and, until Follow or OnDepth
+// provide it, the order book from market and balances from account. A RestClient serves
+// as all three.
+func New(trading zb.Trading, market zb.MarketData, account zb.AccountReader, config Config, accessKey, secretKey string) (*Grid, error) {
+	if config.Levels <= 0 || config.Spacing <= 0 || config.Amount <= 0 {
+		return nil, &zb.ApiError{Code: zb.InvalidArgument, Message: "Levels, spacing and amount must be positive"}
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+	i := strings.Index(config.Symbol, "_")
+	if i < 0 {
+		return nil, &zb.ApiError{Code: zb.InvalidArgument, Message: "Invalid symbol: " + config.Symbol}
+	}
+	return &Grid{trading: trading, market: market, account: account, config: config, accessKey: accessKey, secretKey: secretKey, base: config.Symbol[:i], levels: map[int]*Level{}, unknown: map[int]bool{}}, nil
+}
+
+func (g *Grid) Follow(ws zb.Streamer) {
+	ws.SubscribeDepth(g.config.Symbol, g.OnDepth)
+}
+
+func (g *Grid) OnDepth(depth zb.Depth) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.depth = &depth
+}
+
+// Levels returns the ladder from the lowest price up.
+func (g *Grid) Levels() []Level {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var levels []Level
+	for _, level := range g.levels {
+		levels = append(levels, *level)
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i].Slot < levels[j].Slot })
+	return levels
+}
+
+// Fills returns the orders of the ladder that traded, filled ones and partially filled
+// ones cancelled by a rebuild, in the order they were found.
+func (g *Grid) Fills() []zb.Order {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]zb.Order(nil), g.fills...)
+}
+
+// Run maintains the ladder every poll interval until ctx is done, then cancels its
+// orders. Errors of single orders are retried on the next poll.
+func (g *Grid) Run(ctx context.Context) error {
+	symbols, err := g.market.GetSymbols()
+	if err != nil {
+		return err
+	}
+	scales, ok := symbols[g.config.Symbol]
+	if !ok {
+		return &zb.ApiError{Code: zb.InvalidArgument, Message: "Unknown symbol: " + g.config.Symbol}
+	}
+	g.scales = scales
+
+	for {
+		g.step()
+		timer := time.NewTimer(g.config.PollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			g.reconcile()
+			g.cancelAll()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// step checks the working orders, rebuilds the ladder when the mid price left the
+// requote band and places the orders missing from it.
+func (g *Grid) step() {
+	mid, err := g.mid()
+	if err != nil {
+		return
+	}
+	g.reconcile()
+
+	g.mu.Lock()
+	rebuild := g.center == 0 || g.config.Requote > 0 && math.Abs(mid-g.center)/g.center > g.config.Requote
+	g.mu.Unlock()
+	if rebuild {
+		if !g.cancelAll() {
+			return
+		}
+		g.build(mid)
+	}
+
+	g.sync()
+	g.placeMissing()
+}
+
+func (g *Grid) mid() (float64, error) {
+	g.mu.Lock()
+	depth := g.depth
+	g.mu.Unlock()
+	if depth == nil {
+		fetched, err := g.market.GetDepth(g.config.Symbol, 10)
+		if err != nil {
+			return 0, err
+		}
+		depth = &fetched
+	}
+
+	ask, bid := 0.0, 0.0
+	for _, e := range depth.Asks {
+		if ask == 0 || e.Price < ask {
+			ask = e.Price
+		}
+	}
+	for _, e := range depth.Bids {
+		bid = math.Max(bid, e.Price)
+	}
+	if ask == 0 || bid == 0 {
+		return 0, errors.New("No depth to price " + g.config.Symbol)
+	}
+	return (ask + bid) / 2, nil
+}
+
+// build lays out a new ladder around center, with the center level free.
+func (g *Grid) build(center float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.center = center
+	g.levels = map[int]*Level{}
+	for i := 1; i <= g.config.Levels; i++ {
+		g.levels[-i] = &Level{Slot: -i, Price: g.price(-i), TradeType: zb.Buy}
+		g.levels[i] = &Level{Slot: i, Price: g.price(i), TradeType: zb.Sell}
+	}
+}
+
+// price of slot, rounded away from the center to the price scale. g.mu must be held.
+func (g *Grid) price(slot int) float64 {
+	price := g.center * math.Pow(1+g.config.Spacing, float64(slot))
+	pow := math.Pow(10, float64(g.scales.PriceScale))
+	if slot < 0 {
+		return math.Floor(price*pow+epsilon) / pow
+	}
+	return math.Ceil(price*pow-epsilon) / pow
+}
+
+// reconcile looks for the orders of unknown levels among the open orders of the market.
+// A level whose order is not found is free to be placed again.
+func (g *Grid) reconcile() {
+	g.mu.Lock()
+	n := len(g.unknown)
+	g.mu.Unlock()
+	if n == 0 {
+		return
+	}
+	open, err := g.trading.GetOpenOrders(g.config.Symbol, g.accessKey, g.secretKey)
+	if err != nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	used := map[uint64]bool{}
+	for _, level := range g.levels {
+		used[level.Id] = true
+	}
+	for slot := range g.unknown {
+		delete(g.unknown, slot)
+		level, ok := g.levels[slot]
+		if !ok || level.Id != 0 {
+			continue
+		}
+		for _, order := range open {
+			if !used[order.Id] && order.TradeType == level.TradeType && math.Abs(order.Price-level.Price) <= epsilon {
+				level.Id, used[order.Id] = order.Id, true
+				break
+			}
+		}
+	}
+}
+
+// sync polls the working orders. A filled level makes way for the opposite order one
+// level further, a level cancelled from outside is placed again. Buys are polled from
+// the top down and sells from the bottom up, so when the price gaps through several
+// levels the nearer one is gone before the one behind it needs its place.
+func (g *Grid) sync() {
+	levels := g.Levels()
+	sort.SliceStable(levels, func(i, j int) bool {
+		if levels[i].TradeType != levels[j].TradeType {
+			return levels[i].TradeType == zb.Buy
+		}
+		if levels[i].TradeType == zb.Buy {
+			return levels[i].Slot > levels[j].Slot
+		}
+		return levels[i].Slot < levels[j].Slot
+	})
+	for _, level := range levels {
+		if level.Id == 0 {
+			continue
+		}
+		order, err := g.trading.GetOrder(g.config.Symbol, level.Id, g.accessKey, g.secretKey)
+		if err != nil {
+			continue
+		}
+
+		g.mu.Lock()
+		current, ok := g.levels[level.Slot]
+		if !ok || current.Id != level.Id {
+			g.mu.Unlock()
+			continue
+		}
+		switch order.Status {
+		case zb.Finished:
+			g.fills = append(g.fills, order)
+			delete(g.levels, level.Slot)
+			slot, tradeType := level.Slot+1, zb.Sell
+			if level.TradeType == zb.Sell {
+				slot, tradeType = level.Slot-1, zb.Buy
+			}
+			if _, taken := g.levels[slot]; !taken {
+				g.levels[slot] = &Level{Slot: slot, Price: g.price(slot), TradeType: tradeType}
+			}
+		case zb.Cancelled:
+			current.Id = 0
+		}
+		g.mu.Unlock()
+	}
+}
+
+// placeMissing places the free levels the inventory limits allow, nearest to the center first.
+func (g *Grid) placeMissing() {
+	account, err := g.account.GetAccount(g.accessKey, g.secretKey)
+	if err != nil {
+		return
+	}
+	balance := 0.0
+	for _, asset := range account.Assets {
+		if asset.Coin.Key == g.base {
+			balance = asset.Total()
+		}
+	}
+
+	pow := math.Pow(10, float64(g.scales.AmountScale))
+	amount := math.Floor(g.config.Amount*pow+epsilon) / pow
+	if amount <= 0 {
+		return
+	}
+
+	levels := g.Levels()
+	g.mu.Lock()
+	unknown := map[int]bool{}
+	for slot := range g.unknown {
+		unknown[slot] = true
+	}
+	g.mu.Unlock()
+	buys, sells := 0.0, 0.0
+	for _, level := range levels {
+		working := level.Id != 0 || unknown[level.Slot]
+		if working && level.TradeType == zb.Buy {
+			buys += amount
+		}
+		if working && level.TradeType == zb.Sell {
+			sells += amount
+		}
+	}
+	sort.SliceStable(levels, func(i, j int) bool { return abs(levels[i].Slot) < abs(levels[j].Slot) })
+
+	for _, level := range levels {
+		if level.Id != 0 || unknown[level.Slot] {
+			continue
+		}
+		if level.TradeType == zb.Buy && g.config.MaxBase > 0 && balance+buys+amount > g.config.MaxBase+epsilon {
+			continue
+		}
+		if level.TradeType == zb.Sell && balance-sells-amount < g.config.MinBase-epsilon {
+			continue
+		}
+
+		id, err := g.trading.PlaceOrder(g.config.Symbol, level.Price, amount, level.TradeType, g.accessKey, g.secretKey)
+		apiError, refused := err.(*zb.ApiError)
+		if refused && apiError.Code == zb.TooFrequent {
+			return
+		}
+		if refused && apiError.Code != zb.GeneralError {
+			continue
+		}
+
+		g.mu.Lock()
+		if current, ok := g.levels[level.Slot]; ok && current.Id == 0 {
+			if err == nil {
+				current.Id = id
+			} else {
+				// the order may be live, it is looked for before the level is placed again
+				g.unknown[level.Slot] = true
+			}
+		}
+		g.mu.Unlock()
+		if level.TradeType == zb.Buy {
+			buys += amount
+		} else {
+			sells += amount
+		}
+	}
+}
+
+// cancelAll cancels the working orders of the ladder and reports whether all of them
+// are gone, which is not known while orders of unknown levels were not found yet.
+func (g *Grid) cancelAll() bool {
+	g.mu.Lock()
+	all := len(g.unknown) == 0
+	g.mu.Unlock()
+	for _, level := range g.Levels() {
+		if level.Id == 0 {
+			continue
+		}
+		err := g.trading.CancelOrder(g.config.Symbol, level.Id, g.accessKey, g.secretKey)
+		if apiError, ok := err.(*zb.ApiError); err != nil && !(ok && apiError.Code == zb.OrderNotFound) {
+			all = false
+			continue
+		}
+
+		// a fill that came before the cancel still counts
+		if order, err := g.trading.GetOrder(g.config.Symbol, level.Id, g.accessKey, g.secretKey); err == nil && order.TradeAmount > 0 {
+			g.mu.Lock()
+			g.fills = append(g.fills, order)
+			g.mu.Unlock()
+		}
+		g.mu.Lock()
+		if current, ok := g.levels[level.Slot]; ok && current.Id == level.Id {
+			current.Id = 0
+		}
+		g.mu.Unlock()
+	}
+	return all
+}
+
+func abs(slot int) int {
+	if slot < 0 {
+		return -slot
+	}
+	return slot
+}
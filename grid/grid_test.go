@@ -0,0 +1,142 @@
+package grid
+
+import (
+	"context"
+	"github.com/berryland/zb"
+	"github.com/berryland/zb/zbtest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func depth(mid float64) zb.Depth {
+	return zb.Depth{Asks: []zb.DepthEntry{{Price: mid + 1, Volume: 0.01}}, Bids: []zb.DepthEntry{{Price: mid - 1, Volume: 0.01}}}
+}
+
+func prices(levels []Level) []float64 {
+	var prices []float64
+	for _, level := range levels {
+		prices = append(prices, level.Price)
+	}
+	return prices
+}
+
+func TestGrid_step(t *testing.T) {
+	paper := zb.NewPaperClient(map[string]float64{"btc": 1, "usdt": 10000}, 0)
+	g, err := New(paper, nil, paper, Config{Symbol: "btc_usdt", Levels: 2, Spacing: 0.005, Amount: 0.1, Requote: 0.02, MaxBase: 1.15}, "", "")
+	assert.Nil(t, err)
+	g.scales = zb.SymbolConfig{AmountScale: 4, PriceScale: 2}
+
+	g.OnDepth(depth(11000))
+	g.step()
+	levels := g.Levels()
+	assert.Equal(t, []float64{10890.81, 10945.27, 11055, 11110.28}, prices(levels))
+	// the second buy would take the balance over MaxBase
+	assert.Equal(t, uint64(0), levels[0].Id)
+	open, _ := paper.GetOpenOrders("btc_usdt", "", "")
+	assert.Len(t, open, 3)
+
+	paper.OnTrades("btc_usdt", []zb.Trade{{Price: 10940, Amount: 0.1, TradeType: zb.Sell}})
+	g.step()
+	levels = g.Levels()
+	assert.Equal(t, []float64{10890.81, 11000, 11055, 11110.28}, prices(levels))
+	assert.Equal(t, zb.Sell, levels[1].TradeType)
+	assert.NotEqual(t, uint64(0), levels[1].Id)
+	assert.Equal(t, uint64(0), levels[0].Id)
+	assert.Len(t, g.Fills(), 1)
+
+	paper.OnTrades("btc_usdt", []zb.Trade{{Price: 11000, Amount: 0.1, TradeType: zb.Buy}})
+	g.step()
+	levels = g.Levels()
+	assert.Equal(t, []float64{10890.81, 10945.27, 11055, 11110.28}, prices(levels))
+	assert.Equal(t, zb.Buy, levels[1].TradeType)
+	assert.NotEqual(t, uint64(0), levels[1].Id)
+
+	g.OnDepth(depth(11300))
+	g.step()
+	assert.Equal(t, []float64{11187.84, 11243.78, 11356.5, 11413.29}, prices(g.Levels()))
+	open, _ = paper.GetOpenOrders("btc_usdt", "", "")
+	assert.Len(t, open, 3)
+	for _, order := range open {
+		assert.True(t, order.Price > 11000)
+	}
+}
+
+func TestGrid_Gap(t *testing.T) {
+	paper := zb.NewPaperClient(map[string]float64{"btc": 1, "usdt": 10000}, 0)
+	g, _ := New(paper, nil, paper, Config{Symbol: "btc_usdt", Levels: 2, Spacing: 0.005, Amount: 0.1}, "", "")
+	g.scales = zb.SymbolConfig{AmountScale: 4, PriceScale: 2}
+
+	g.OnDepth(depth(11000))
+	g.step()
+	open, _ := paper.GetOpenOrders("btc_usdt", "", "")
+	assert.Len(t, open, 4)
+
+	// the price falls through both buys at once, each makes way for a sell
+	paper.OnTrades("btc_usdt", []zb.Trade{{Price: 10880, Amount: 0.2, TradeType: zb.Sell}})
+	g.step()
+	levels := g.Levels()
+	assert.Equal(t, []float64{10945.27, 11000, 11055, 11110.28}, prices(levels))
+	for _, level := range levels {
+		assert.Equal(t, zb.Sell, level.TradeType)
+		assert.NotEqual(t, uint64(0), level.Id)
+	}
+	assert.Len(t, g.Fills(), 2)
+}
+
+// lossyTrading places the next orders but loses their response, as an unreadable one.
+type lossyTrading struct {
+	*zb.PaperClient
+	lose int
+}
+
+func (t *lossyTrading) PlaceOrder(symbol string, price, amount float64, tradeType zb.TradeType, accessKey, secretKey string) (uint64, error) {
+	id, err := t.PaperClient.PlaceOrder(symbol, price, amount, tradeType, accessKey, secretKey)
+	if err == nil && t.lose > 0 {
+		t.lose--
+		return 0, &zb.ApiError{Code: zb.GeneralError, Message: "Malformed response"}
+	}
+	return id, err
+}
+
+func TestGrid_Unknown(t *testing.T) {
+	paper := zb.NewPaperClient(map[string]float64{"btc": 1, "usdt": 10000}, 0)
+	g, _ := New(&lossyTrading{PaperClient: paper, lose: 1}, nil, paper, Config{Symbol: "btc_usdt", Levels: 1, Spacing: 0.005, Amount: 0.1}, "", "")
+	g.scales = zb.SymbolConfig{AmountScale: 4, PriceScale: 2}
+
+	g.OnDepth(depth(11000))
+	g.step()
+	g.step()
+	g.step()
+	open, _ := paper.GetOpenOrders("btc_usdt", "", "")
+	assert.Len(t, open, 2)
+	for _, level := range g.Levels() {
+		assert.NotEqual(t, uint64(0), level.Id)
+	}
+}
+
+func TestGrid_Run(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	paper := zb.NewPaperClient(map[string]float64{"btc": 1, "usdt": 10000}, 0)
+	g, _ := New(paper, s.RestClient(), paper, Config{Symbol: "eth_usdt", Levels: 1, Spacing: 0.01, Amount: 0.12345, PollInterval: 10 * time.Millisecond}, "", "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- g.Run(ctx) }()
+	time.Sleep(50 * time.Millisecond)
+
+	// eth_usdt has no depth on the server and none was followed yet
+	assert.Empty(t, g.Levels())
+	g.OnDepth(depth(1000))
+	time.Sleep(50 * time.Millisecond)
+	open, _ := paper.GetOpenOrders("eth_usdt", "", "")
+	assert.Len(t, open, 1)
+	assert.InDelta(t, 990.09, open[0].Price, 1e-9)
+	assert.InDelta(t, 0.123, open[0].TotalAmount, 1e-9)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+	open, _ = paper.GetOpenOrders("eth_usdt", "", "")
+	assert.Empty(t, open)
+}
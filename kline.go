@@ -0,0 +1,157 @@
+package zb
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type Period uint8
+
+const (
+	OneMinute Period = iota
+	ThreeMinutes
+	FiveMinutes
+	FifteenMinutes
+	ThirtyMinutes
+	OneHour
+	TwoHours
+	FourHours
+	SixHours
+	TwelveHours
+	OneDay
+	ThreeDays
+	OneWeek
+)
+
+var periods = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"1min", time.Minute},
+	{"3min", 3 * time.Minute},
+	{"5min", 5 * time.Minute},
+	{"15min", 15 * time.Minute},
+	{"30min", 30 * time.Minute},
+	{"1hour", time.Hour},
+	{"2hour", 2 * time.Hour},
+	{"4hour", 4 * time.Hour},
+	{"6hour", 6 * time.Hour},
+	{"12hour", 12 * time.Hour},
+	{"1day", 24 * time.Hour},
+	{"3day", 3 * 24 * time.Hour},
+	{"1week", 7 * 24 * time.Hour},
+}
+
+// ParsePeriod accepts the kline types of zb, e.g. "5min" or "1day".
+func ParsePeriod(string string) (Period, error) {
+	for i, p := range periods {
+		if p.name == string {
+			return Period(i), nil
+		}
+	}
+	return 0, &ApiError{Code: InvalidArgument, Message: "Unknown kline period: " + string}
+}
+
+// String returns the kline type of zb, or e.g. "Period(13)" for an unknown period.
+func (p Period) String() string {
+	if int(p) >= len(periods) {
+		return "Period(" + strconv.Itoa(int(p)) + ")"
+	}
+	return periods[p].name
+}
+
+// Duration returns 0 for an unknown period.
+func (p Period) Duration() time.Duration {
+	if int(p) >= len(periods) {
+		return 0
+	}
+	return periods[p].duration
+}
+
+func (p Period) millis() uint64 {
+	return uint64(p.Duration() / time.Millisecond)
+}
+
+// KlineGap is a range of missing candles, From inclusive and To exclusive, in milliseconds.
+type KlineGap struct {
+	From uint64
+	To   uint64
+}
+
+const (
+	maxKlineSize         = uint16(1000)
+	defaultKlineInterval = 100 * time.Millisecond
+)
+
+func (c *RestClient) BackfillKlines(ctx context.Context, symbol string, period Period, from, to uint64) ([]Kline, []KlineGap, error) {
+	return BackfillKlines(ctx, c, symbol, period, from, to)
+}
+
+// BackfillKlines pages GetKlines forward from from until to, both in milliseconds,
+// de-duplicates overlapping candles and reports the intervals zb has no candle for.
+func BackfillKlines(ctx context.Context, market MarketData, symbol string, period Period, from, to uint64) ([]Kline, []KlineGap, error) {
+	if period.Duration() == 0 {
+		return nil, nil, &ApiError{Code: InvalidArgument, Message: "Unknown kline period: " + period.String()}
+	}
+
+	byTime := map[uint64]Kline{}
+	since := from
+	for since < to {
+		batch, err := market.GetKlines(symbol, period.String(), since, maxKlineSize)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		last := uint64(0)
+		for _, k := range batch {
+			if k.Time >= from && k.Time < to {
+				byTime[k.Time] = k
+			}
+			if k.Time > last {
+				last = k.Time
+			}
+		}
+		if len(batch) < int(maxKlineSize) || last < since {
+			break
+		}
+		since = last + 1
+
+		timer := time.NewTimer(defaultKlineInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	klines := make([]Kline, 0, len(byTime))
+	for _, k := range byTime {
+		klines = append(klines, k)
+	}
+	sort.Slice(klines, func(i, j int) bool { return klines[i].Time < klines[j].Time })
+	return klines, findKlineGaps(klines, period, from, to), nil
+}
+
+func findKlineGaps(klines []Kline, period Period, from, to uint64) []KlineGap {
+	step := period.millis()
+	end := to
+	if now := nowMillis(); now < end {
+		end = now - now%step
+	}
+
+	var gaps []KlineGap
+	expected := from
+	for _, k := range klines {
+		if k.Time >= expected+step {
+			gaps = append(gaps, KlineGap{From: expected, To: k.Time})
+		}
+		expected = k.Time + step
+	}
+	if end >= expected+step {
+		gaps = append(gaps, KlineGap{From: expected, To: end})
+	}
+	return gaps
+}
@@ -0,0 +1,52 @@
+package zb_test
+
+import (
+	"context"
+	"github.com/berryland/zb"
+	"github.com/berryland/zb/zbtest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParsePeriod(t *testing.T) {
+	period, err := zb.ParsePeriod("15min")
+	assert.Nil(t, err)
+	assert.Equal(t, zb.FifteenMinutes, period)
+	assert.Equal(t, 15*time.Minute, period.Duration())
+	assert.Equal(t, "1week", zb.OneWeek.String())
+
+	_, err = zb.ParsePeriod("2min")
+	assert.NotNil(t, err)
+
+	unknown := zb.OneWeek + 1
+	assert.Equal(t, "Period(13)", unknown.String())
+	assert.Equal(t, time.Duration(0), unknown.Duration())
+	_, _, err = zb.BackfillKlines(context.Background(), zb.NewRestClient(), "btc_usdt", unknown, 0, 60000)
+	assert.Equal(t, zb.InvalidArgument, err.(*zb.ApiError).Code)
+
+	_, err = zb.NewRestClient().GetKlines("btc_usdt", "2min", 0, 10)
+	assert.Equal(t, zb.InvalidArgument, err.(*zb.ApiError).Code)
+}
+
+func TestBackfillKlines(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+
+	from := uint64(1516029900000)
+	var klines []zb.Kline
+	for i := uint64(0); i < 2500; i++ {
+		if i == 1200 || i == 1201 {
+			continue
+		}
+		klines = append(klines, zb.Kline{Time: from + i*60000, Close: float64(i)})
+	}
+	s.SetKlines("btc_usdt", klines)
+
+	backfilled, gaps, err := s.RestClient().BackfillKlines(context.Background(), "btc_usdt", zb.OneMinute, from, from+3000*60000)
+	assert.Nil(t, err)
+	assert.Len(t, backfilled, 2498)
+	assert.Equal(t, klines, backfilled)
+	assert.Equal(t, []zb.KlineGap{{From: from + 1200*60000, To: from + 1202*60000}, {From: from + 2500*60000, To: from + 3000*60000}}, gaps)
+	assert.Equal(t, 3, s.Requests("kline"))
+}
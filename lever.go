@@ -0,0 +1,234 @@
+package zb
+
+import (
+	json "github.com/buger/jsonparser"
+	"strconv"
+)
+
+type LeverBalance struct {
+	Coin      string
+	Available float64
+	Freeze    float64
+	LoanIn    float64
+	LoanOut   float64
+	CanLoanIn float64
+	Overdraft float64
+}
+
+// LeverAsset is the leveraged account of one market, Base holds the traded coin
+// and Quote the coin it is priced in.
+type LeverAsset struct {
+	Market      string
+	Level       uint8
+	UnwindPrice float64
+	Base        LeverBalance
+	Quote       LeverBalance
+}
+
+type LeverBill struct {
+	Id      uint64
+	Coin    string
+	Type    string
+	Change  float64
+	Balance float64
+	Time    uint64
+}
+
+type LoanStatus uint8
+
+const (
+	LoanRepaying LoanStatus = iota
+	LoanRepaid
+	LoanOverdue
+)
+
+type Loan struct {
+	Id                uint64
+	Market            string
+	Coin              string
+	Amount            float64
+	Repaid            float64
+	InterestRateOfDay float64
+	RepaymentDays     uint8
+	Status            LoanStatus
+	Time              uint64
+}
+
+type Repayment struct {
+	Id       uint64
+	Amount   float64
+	Interest float64
+	Time     uint64
+}
+
+func (c *RestClient) GetLeverAssets(accessKey, secretKey string) ([]LeverAsset, error) {
+	bytes, err := c.doTrade("getLeverAssetsInfo", map[string]string{}, accessKey, secretKey)
+	if err != nil {
+		return []LeverAsset{}, err
+	}
+
+	var assets []LeverAsset
+	json.ArrayEach(bytes, func(value []byte, dataType json.ValueType, offset int, err error) {
+		market, _ := json.GetString(value, "key")
+		level, _ := json.GetInt(value, "level")
+		assets = append(assets, LeverAsset{
+			Market:      market,
+			Level:       uint8(level),
+			UnwindPrice: getFloat(value, "unwindPrice"),
+			Base:        parseLeverBalance(value, "c"),
+			Quote:       parseLeverBalance(value, "f"),
+		})
+	}, "message", "datas", "levers")
+	return assets, nil
+}
+
+// parseLeverBalance reads the fields zb prefixes with c for the base coin and f for the quote coin.
+func parseLeverBalance(value []byte, prefix string) LeverBalance {
+	coin, _ := json.GetString(value, prefix+"ShowName")
+	return LeverBalance{
+		Coin:      coin,
+		Available: getFloat(value, prefix+"Available"),
+		Freeze:    getFloat(value, prefix+"Freeze"),
+		LoanIn:    getFloat(value, prefix+"LoanIn"),
+		LoanOut:   getFloat(value, prefix+"LoanOut"),
+		CanLoanIn: getFloat(value, prefix+"CanLoanIn"),
+		Overdraft: getFloat(value, prefix+"Overdraft"),
+	}
+}
+
+func (c *RestClient) GetLeverBills(coin string, page uint64, size uint16, accessKey, secretKey string) ([]LeverBill, error) {
+	q := map[string]string{
+		"coin":      coin,
+		"dataType":  "0",
+		"pageIndex": strconv.FormatUint(page, 10),
+		"pageSize":  strconv.FormatUint(uint64(size), 10),
+	}
+	bytes, err := c.doTrade("getLeverBills", q, accessKey, secretKey)
+	if err != nil {
+		return []LeverBill{}, err
+	}
+
+	var bills []LeverBill
+	json.ArrayEach(bytes, func(value []byte, dataType json.ValueType, offset int, err error) {
+		coinType, _ := json.GetString(value, "coinType")
+		typeName, _ := json.GetString(value, "typeName")
+		bills = append(bills, LeverBill{
+			Id:      getUint(value, "id"),
+			Coin:    coinType,
+			Type:    typeName,
+			Change:  getFloat(value, "changeCoin"),
+			Balance: getFloat(value, "coinBalance"),
+			Time:    getUint(value, "createTime"),
+		})
+	}, "message", "datas", "list")
+	return bills, nil
+}
+
+// TransferInLever moves amount of coin from the spot account into the lever account of market.
+func (c *RestClient) TransferInLever(coin string, market string, amount float64, accessKey, secretKey string) error {
+	return c.transferLever("transferInLever", coin, market, amount, accessKey, secretKey)
+}
+
+// TransferOutLever moves amount of coin from the lever account of market back to the spot account.
+func (c *RestClient) TransferOutLever(coin string, market string, amount float64, accessKey, secretKey string) error {
+	return c.transferLever("transferOutLever", coin, market, amount, accessKey, secretKey)
+}
+
+func (c *RestClient) transferLever(method string, coin string, market string, amount float64, accessKey, secretKey string) error {
+	q := map[string]string{
+		"coin":       coin,
+		"marketName": market,
+		"amount":     strconv.FormatFloat(amount, 'f', -1, 64),
+	}
+	_, err := c.doTrade(method, q, accessKey, secretKey)
+	return err
+}
+
+// Borrow takes a loan of amount coin into the lever account of market. With loop set
+// the loan is renewed automatically when it is due.
+func (c *RestClient) Borrow(market string, coin string, amount float64, interestRateOfDay float64, repaymentDays uint8, loop bool, safePassword string, accessKey, secretKey string) error {
+	q := map[string]string{
+		"marketName":        market,
+		"coin":              coin,
+		"amount":            strconv.FormatFloat(amount, 'f', -1, 64),
+		"interestRateOfDay": strconv.FormatFloat(interestRateOfDay, 'f', -1, 64),
+		"repaymentDay":      strconv.FormatUint(uint64(repaymentDays), 10),
+		"isLoop":            "0",
+		"safePwd":           safePassword,
+	}
+	if loop {
+		q["isLoop"] = "1"
+	}
+	_, err := c.doTrade("borrow", q, accessKey, secretKey)
+	return err
+}
+
+// Repay pays back amount of the loan, or all of it with full set.
+func (c *RestClient) Repay(loanId uint64, amount float64, full bool, safePassword string, accessKey, secretKey string) error {
+	q := map[string]string{
+		"loanRecordId": strconv.FormatUint(loanId, 10),
+		"repayAmount":  strconv.FormatFloat(amount, 'f', -1, 64),
+		"repayType":    "1",
+		"safePwd":      safePassword,
+	}
+	if full {
+		q["repayType"] = "0"
+	}
+	_, err := c.doTrade("doRepay", q, accessKey, secretKey)
+	return err
+}
+
+func (c *RestClient) GetLoans(market string, page uint64, size uint16, accessKey, secretKey string) ([]Loan, error) {
+	q := map[string]string{
+		"marketName": market,
+		"pageIndex":  strconv.FormatUint(page, 10),
+		"pageSize":   strconv.FormatUint(uint64(size), 10),
+	}
+	bytes, err := c.doTrade("getLoanRecords", q, accessKey, secretKey)
+	if err != nil {
+		return []Loan{}, err
+	}
+
+	var loans []Loan
+	json.ArrayEach(bytes, func(value []byte, dataType json.ValueType, offset int, err error) {
+		marketName, _ := json.GetString(value, "marketName")
+		coinName, _ := json.GetString(value, "coinName")
+		repaymentDay, _ := json.GetInt(value, "repaymentDay")
+		status, _ := json.GetInt(value, "status")
+		loans = append(loans, Loan{
+			Id:                getUint(value, "id"),
+			Market:            marketName,
+			Coin:              coinName,
+			Amount:            getFloat(value, "amount"),
+			Repaid:            getFloat(value, "hasRepay"),
+			InterestRateOfDay: getFloat(value, "interestRateOfDay"),
+			RepaymentDays:     uint8(repaymentDay),
+			Status:            LoanStatus(status),
+			Time:              getUint(value, "createTime"),
+		})
+	}, "message", "datas", "list")
+	return loans, nil
+}
+
+func (c *RestClient) GetRepayments(loanId uint64, page uint64, size uint16, accessKey, secretKey string) ([]Repayment, error) {
+	q := map[string]string{
+		"loanRecordId": strconv.FormatUint(loanId, 10),
+		"pageIndex":    strconv.FormatUint(page, 10),
+		"pageSize":     strconv.FormatUint(uint64(size), 10),
+	}
+	bytes, err := c.doTrade("getRepayments", q, accessKey, secretKey)
+	if err != nil {
+		return []Repayment{}, err
+	}
+
+	var repayments []Repayment
+	json.ArrayEach(bytes, func(value []byte, dataType json.ValueType, offset int, err error) {
+		repayments = append(repayments, Repayment{
+			Id:       getUint(value, "id"),
+			Amount:   getFloat(value, "benJin"),
+			Interest: getFloat(value, "interest"),
+			Time:     getUint(value, "repayDate"),
+		})
+	}, "message", "datas", "list")
+	return repayments, nil
+}
@@ -0,0 +1,51 @@
+package zb_test
+
+import (
+	"github.com/berryland/zb"
+	"github.com/berryland/zb/zbtest"
+	"github.com/stretchr/testify/assert"
+	"net/url"
+	"testing"
+)
+
+func TestRestClientOffline_GetLeverAssets(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	s.Respond("getLeverAssetsInfo", `{"code":1000,"message":{"des":"success","isSuc":true,"datas":{"levers":[
+		{"key":"btcusdt","level":1,"unwindPrice":5000,"cShowName":"BTC","cAvailable":"0.5","cFreeze":0.1,"cLoanIn":0.2,"fShowName":"USDT","fAvailable":1000,"fLoanOut":"10"}
+	]}}}`)
+
+	assets, err := s.RestClient().GetLeverAssets(s.AccessKey, s.SecretKey)
+	assert.Nil(t, err)
+	assert.Equal(t, []zb.LeverAsset{{
+		Market:      "btcusdt",
+		Level:       1,
+		UnwindPrice: 5000,
+		Base:        zb.LeverBalance{Coin: "BTC", Available: 0.5, Freeze: 0.1, LoanIn: 0.2},
+		Quote:       zb.LeverBalance{Coin: "USDT", Available: 1000, LoanOut: 10},
+	}}, assets)
+}
+
+func TestRestClientOffline_Borrow(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	s.Handle("borrow", func(params url.Values) string {
+		assert.Equal(t, "btcusdt", params.Get("marketName"))
+		assert.Equal(t, "1", params.Get("isLoop"))
+		return `{"code":1000,"message":"success"}`
+	})
+	s.Respond("getLoanRecords", `{"code":1000,"message":{"des":"success","isSuc":true,"datas":{"list":[
+		{"id":42,"marketName":"btcusdt","coinName":"usdt","amount":"100","hasRepay":"20","interestRateOfDay":0.001,"repaymentDay":10,"status":0,"createTime":1516029900000}
+	]}}}`)
+
+	c := s.RestClient()
+	assert.Nil(t, c.Borrow("btcusdt", "usdt", 100, 0.001, 10, true, "safe", s.AccessKey, s.SecretKey))
+
+	loans, err := c.GetLoans("btcusdt", 1, 10, s.AccessKey, s.SecretKey)
+	assert.Nil(t, err)
+	assert.Equal(t, zb.Loan{Id: 42, Market: "btcusdt", Coin: "usdt", Amount: 100, Repaid: 20, InterestRateOfDay: 0.001, RepaymentDays: 10, Status: zb.LoanRepaying, Time: 1516029900000}, loans[0])
+
+	s.InjectError("doRepay", zb.InsufficientFund)
+	err = c.Repay(42, 80, true, "safe", s.AccessKey, s.SecretKey)
+	assert.Equal(t, zb.InsufficientFund, err.(*zb.ApiError).Code)
+}
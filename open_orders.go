@@ -0,0 +1,92 @@
+package zb
+
+import (
+	"sort"
+	"sync"
+)
+
+// OpenOrdersView is the result of reconciling locally placed orders with the exchange.
+type OpenOrdersView struct {
+	// Open orders are live on the exchange and tracked locally
+	Open []Order
+	// Orphaned orders are live on the exchange but were not placed by this process,
+	// or were placed before a restart and never tracked again
+	Orphaned []Order
+	// Missing ids are tracked locally but not live on the exchange, because they were
+	// filled, cancelled elsewhere or never reached zb
+	Missing []uint64
+}
+
+// OpenOrders keeps the ids of orders placed by this process for one symbol.
+type OpenOrders struct {
+	mu        sync.Mutex
+	trading   Trading
+	symbol    string
+	accessKey string
+	secretKey string
+	tracked   map[uint64]bool
+}
+
+// NewOpenOrders creates a tracker, ids restores the orders tracked before a restart.
+func NewOpenOrders(trading Trading, symbol string, accessKey, secretKey string, ids ...uint64) *OpenOrders {
+	o := &OpenOrders{trading: trading, symbol: symbol, accessKey: accessKey, secretKey: secretKey, tracked: map[uint64]bool{}}
+	for _, id := range ids {
+		o.tracked[id] = true
+	}
+	return o
+}
+
+func (o *OpenOrders) Track(id uint64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.tracked[id] = true
+}
+
+func (o *OpenOrders) Forget(id uint64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.tracked, id)
+}
+
+func (o *OpenOrders) Tracked() []uint64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var ids []uint64
+	for id := range o.tracked {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// Reconcile fetches the open orders of the symbol and compares them with the tracked ids.
+// It does not change what is tracked, callers decide whether to Forget missing orders
+// or Track orphaned ones.
+func (o *OpenOrders) Reconcile() (OpenOrdersView, error) {
+	orders, err := o.trading.GetOpenOrders(o.symbol, o.accessKey, o.secretKey)
+	if err != nil {
+		return OpenOrdersView{}, err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var view OpenOrdersView
+	live := map[uint64]bool{}
+	for _, order := range orders {
+		live[order.Id] = true
+		if o.tracked[order.Id] {
+			view.Open = append(view.Open, order)
+		} else {
+			view.Orphaned = append(view.Orphaned, order)
+		}
+	}
+	for id := range o.tracked {
+		if !live[id] {
+			view.Missing = append(view.Missing, id)
+		}
+	}
+	sort.Slice(view.Missing, func(i, j int) bool { return view.Missing[i] < view.Missing[j] })
+	return view, nil
+}
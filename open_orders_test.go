@@ -0,0 +1,41 @@
+package zb_test
+
+import (
+	"github.com/berryland/zb"
+	"github.com/berryland/zb/zbtest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRestClientOffline_GetOpenOrders(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	for i := uint64(1); i <= 12; i++ {
+		s.SetOrder(zb.Order{Id: i, Symbol: "btc_usdt", Status: zb.Pending, TradeType: zb.Buy})
+	}
+	s.SetOrder(zb.Order{Id: 13, Symbol: "btc_usdt", Status: zb.Finished, TradeType: zb.Buy})
+	s.SetOrder(zb.Order{Id: 14, Symbol: "eth_usdt", Status: zb.Pending, TradeType: zb.Buy})
+
+	orders, err := s.RestClient().GetOpenOrders("btc_usdt", s.AccessKey, s.SecretKey)
+	assert.Nil(t, err)
+	assert.Len(t, orders, 12)
+	assert.Equal(t, uint64(12), orders[0].Id)
+}
+
+func TestOpenOrders_Reconcile(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	c := s.RestClient()
+
+	first, _ := c.PlaceOrder("btc_usdt", 10000, 0.1, zb.Buy, s.AccessKey, s.SecretKey)
+	second, _ := c.PlaceOrder("btc_usdt", 10100, 0.1, zb.Buy, s.AccessKey, s.SecretKey)
+	orphan, _ := c.PlaceOrder("btc_usdt", 10200, 0.1, zb.Buy, s.AccessKey, s.SecretKey)
+	s.SetOrder(zb.Order{Id: second, Symbol: "btc_usdt", Status: zb.Finished, TradeType: zb.Buy})
+
+	tracker := zb.NewOpenOrders(c, "btc_usdt", s.AccessKey, s.SecretKey, first, second)
+	view, err := tracker.Reconcile()
+	assert.Nil(t, err)
+	assert.Equal(t, first, view.Open[0].Id)
+	assert.Equal(t, orphan, view.Orphaned[0].Id)
+	assert.Equal(t, []uint64{second}, view.Missing)
+}
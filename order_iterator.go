@@ -0,0 +1,164 @@
+package zb
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	defaultOrderPageSize     = uint16(10)
+	defaultOrderPageInterval = 200 * time.Millisecond
+	maxTooFrequentRetries    = 5
+)
+
+// OrderFilter narrows the orders walked by an OrderIterator. Zero values do not filter.
+type OrderFilter struct {
+	Statuses []OrderStatus
+	// Since and Until bound Order.Time in milliseconds, Since inclusive and Until exclusive
+	Since uint64
+	Until uint64
+	// PageSize defaults to 10 and Interval, the minimum delay between two pages, to 200ms
+	PageSize uint16
+	Interval time.Duration
+}
+
+func (f OrderFilter) match(order Order) bool {
+	if f.Since > 0 && order.Time < f.Since {
+		return false
+	}
+	if f.Until > 0 && order.Time >= f.Until {
+		return false
+	}
+	if len(f.Statuses) == 0 {
+		return true
+	}
+	for _, status := range f.Statuses {
+		if order.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderIterator walks the pages of GetOrders, newest first, and stops at the first page
+// reaching back before Since. Use it like a bufio.Scanner:
+//
+//	it := c.IterOrders(ctx, "btc_usdt", All, OrderFilter{}, accessKey, secretKey)
+//	for it.Next() {
+//		order := it.Order()
+//	}
+//	err := it.Err()
+type OrderIterator struct {
+	ctx       context.Context
+	fetchPage func(page uint64, size uint16) ([]Order, error)
+	filter    OrderFilter
+
+	page    uint64
+	buffer  []Order
+	current Order
+	last    time.Time
+	done    bool
+	err     error
+}
+
+func NewOrderIterator(ctx context.Context, trading Trading, symbol string, tradeType TradeType, filter OrderFilter, accessKey, secretKey string) *OrderIterator {
+	return newOrderIterator(ctx, func(page uint64, size uint16) ([]Order, error) {
+		return trading.GetOrders(symbol, tradeType, page, size, accessKey, secretKey)
+	}, filter)
+}
+
+func newOrderIterator(ctx context.Context, fetchPage func(page uint64, size uint16) ([]Order, error), filter OrderFilter) *OrderIterator {
+	if filter.PageSize == 0 {
+		filter.PageSize = defaultOrderPageSize
+	}
+	if filter.Interval == 0 {
+		filter.Interval = defaultOrderPageInterval
+	}
+	return &OrderIterator{ctx: ctx, fetchPage: fetchPage, filter: filter}
+}
+
+func (c *RestClient) IterOrders(ctx context.Context, symbol string, tradeType TradeType, filter OrderFilter, accessKey, secretKey string) *OrderIterator {
+	return NewOrderIterator(ctx, c, symbol, tradeType, filter, accessKey, secretKey)
+}
+
+func (it *OrderIterator) Next() bool {
+	for {
+		for len(it.buffer) > 0 {
+			order := it.buffer[0]
+			it.buffer = it.buffer[1:]
+			if it.filter.match(order) {
+				it.current = order
+				return true
+			}
+		}
+		if it.done {
+			return false
+		}
+		it.fetch()
+	}
+}
+
+func (it *OrderIterator) Order() Order {
+	return it.current
+}
+
+func (it *OrderIterator) Err() error {
+	return it.err
+}
+
+func (it *OrderIterator) fetch() {
+	it.page++
+	interval := it.filter.Interval
+	for retries := 0; ; retries++ {
+		if err := it.wait(interval); err != nil {
+			it.fail(err)
+			return
+		}
+
+		orders, err := it.fetchPage(it.page, it.filter.PageSize)
+		if apiError, ok := err.(*ApiError); ok {
+			switch {
+			case apiError.Code == OrderNotFound:
+				// zb reports a page past the last one as an unknown order
+				it.done = true
+				return
+			case apiError.Code == TooFrequent && retries < maxTooFrequentRetries:
+				interval *= 2
+				continue
+			}
+		}
+		if err != nil {
+			it.fail(err)
+			return
+		}
+
+		it.buffer = orders
+		it.done = len(orders) < int(it.filter.PageSize)
+		// pages are newest first, no later page can hold an order since Since
+		if it.filter.Since > 0 && len(orders) > 0 && orders[len(orders)-1].Time < it.filter.Since {
+			it.done = true
+		}
+		return
+	}
+}
+
+func (it *OrderIterator) wait(interval time.Duration) error {
+	if !it.last.IsZero() {
+		if d := interval - time.Since(it.last); d > 0 {
+			timer := time.NewTimer(d)
+			select {
+			case <-it.ctx.Done():
+				timer.Stop()
+				return it.ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+	it.last = time.Now()
+	return it.ctx.Err()
+}
+
+func (it *OrderIterator) fail(err error) {
+	it.err = err
+	it.done = true
+}
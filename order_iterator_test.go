@@ -0,0 +1,64 @@
+package zb_test
+
+import (
+	"context"
+	"github.com/berryland/zb"
+	"github.com/berryland/zb/zbtest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestOrderIterator_Next(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	for i := uint64(1); i <= 25; i++ {
+		status := zb.Finished
+		if i%5 == 0 {
+			status = zb.Cancelled
+		}
+		s.SetOrder(zb.Order{Id: i, Symbol: "btc_usdt", Price: 10000, TotalAmount: 1, Status: status, TradeType: zb.Buy, Time: 1000 * i})
+	}
+	s.InjectError("getOrdersIgnoreTradeType", zb.TooFrequent)
+
+	filter := zb.OrderFilter{Statuses: []zb.OrderStatus{zb.Finished}, Since: 3000, Until: 23000, Interval: time.Millisecond}
+	it := s.RestClient().IterOrders(context.Background(), "btc_usdt", zb.All, filter, s.AccessKey, s.SecretKey)
+	var ids []uint64
+	for it.Next() {
+		ids = append(ids, it.Order().Id)
+	}
+
+	assert.Nil(t, it.Err())
+	assert.Equal(t, []uint64{22, 21, 19, 18, 17, 16, 14, 13, 12, 11, 9, 8, 7, 6, 4, 3}, ids)
+	assert.Equal(t, 4, s.Requests("getOrdersIgnoreTradeType"))
+}
+
+func TestOrderIterator_Since(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	for i := uint64(1); i <= 25; i++ {
+		s.SetOrder(zb.Order{Id: i, Symbol: "btc_usdt", Price: 10000, TotalAmount: 1, Status: zb.Finished, TradeType: zb.Buy, Time: 1000 * i})
+	}
+
+	filter := zb.OrderFilter{Since: 18000, Interval: time.Millisecond}
+	it := s.RestClient().IterOrders(context.Background(), "btc_usdt", zb.All, filter, s.AccessKey, s.SecretKey)
+	var ids []uint64
+	for it.Next() {
+		ids = append(ids, it.Order().Id)
+	}
+
+	assert.Nil(t, it.Err())
+	assert.Equal(t, []uint64{25, 24, 23, 22, 21, 20, 19, 18}, ids)
+	assert.Equal(t, 1, s.Requests("getOrdersIgnoreTradeType"))
+}
+
+func TestOrderIterator_Err(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	s.SetOrder(zb.Order{Id: 1, Symbol: "btc_usdt", Status: zb.Pending, TradeType: zb.Sell})
+	s.InjectError("getOrdersNew", zb.Maintained)
+
+	it := s.RestClient().IterOrders(context.Background(), "btc_usdt", zb.Sell, zb.OrderFilter{}, s.AccessKey, s.SecretKey)
+	assert.False(t, it.Next())
+	assert.Equal(t, zb.Maintained, it.Err().(*zb.ApiError).Code)
+}
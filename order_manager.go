@@ -0,0 +1,334 @@
+package zb
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+type OrderEventType uint8
+
+const (
+	OrderAccepted OrderEventType = iota
+	OrderPartiallyFilled
+	OrderFilled
+	OrderCancelled
+	OrderRejected
+)
+
+// OrderEvent reports a change of an order managed by an OrderManager. Order is its state
+// after the change. Fill events carry the amount traded since the previous event and
+// its average price.
+type OrderEvent struct {
+	Type       OrderEventType
+	Order      Order
+	FillAmount float64
+	FillPrice  float64
+	// Err is why zb rejected the order, for OrderRejected only
+	Err error
+}
+
+const (
+	defaultMinPollInterval = 500 * time.Millisecond
+	defaultMaxPollInterval = 10 * time.Second
+)
+
+type managedOrder struct {
+	order    Order
+	seen     bool
+	interval time.Duration
+	next     time.Time
+}
+
+// OrderManager follows orders from placement until they are filled or cancelled and
+// reports every transition to a handler. Orders are polled with GetOrder, quickly after
+// a change and less and less often while nothing happens; Follow makes trades on the
+// websocket trigger a poll of the orders they may have filled. Updates from any other
+// source can be pushed with Update.
+//
+// Events of one order are handed to the handler in order, one at a time. The handler
+// may call back into the OrderManager.
+type OrderManager struct {
+	mu          sync.Mutex
+	trading     Trading
+	accessKey   string
+	secretKey   string
+	handler     func(event OrderEvent)
+	orders      map[uint64]*managedOrder
+	minInterval time.Duration
+	maxInterval time.Duration
+	wake        chan struct{}
+	queue       []OrderEvent
+	delivering  bool
+}
+
+func NewOrderManager(trading Trading, accessKey, secretKey string, handler func(event OrderEvent)) *OrderManager {
+	if handler == nil {
+		handler = func(event OrderEvent) {}
+	}
+	return &OrderManager{
+		trading:     trading,
+		accessKey:   accessKey,
+		secretKey:   secretKey,
+		handler:     handler,
+		orders:      map[uint64]*managedOrder{},
+		minInterval: defaultMinPollInterval,
+		maxInterval: defaultMaxPollInterval,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// SetPollIntervals sets how soon an order is polled after a change, and the longest
+// the interval grows to while it does not change. They default to 500ms and 10s.
+func (m *OrderManager) SetPollIntervals(min, max time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.minInterval, m.maxInterval = min, max
+}
+
+// Place submits an order and manages it. An order zb refuses is reported as
+// OrderRejected, other errors, a GeneralError such as an unreadable response included,
+// leave it unknown whether the order exists.
+func (m *OrderManager) Place(symbol string, price, amount float64, tradeType TradeType) (uint64, error) {
+	id, err := m.trading.PlaceOrder(symbol, price, amount, tradeType, m.accessKey, m.secretKey)
+	if err != nil {
+		if apiError, ok := err.(*ApiError); ok && apiError.Code != GeneralError {
+			order := Order{Symbol: symbol, Price: price, TotalAmount: amount, TradeType: tradeType, Time: nowMillis()}
+			m.mu.Lock()
+			m.queue = append(m.queue, OrderEvent{Type: OrderRejected, Order: order, Err: err})
+			m.mu.Unlock()
+			m.deliver()
+		}
+		return 0, err
+	}
+
+	m.mu.Lock()
+	order := Order{Id: id, Symbol: symbol, Price: price, TotalAmount: amount, TradeType: tradeType, Status: Pending, Time: nowMillis()}
+	m.orders[id] = &managedOrder{order: order, seen: true, interval: m.minInterval, next: time.Now().Add(m.minInterval)}
+	m.queue = append(m.queue, OrderEvent{Type: OrderAccepted, Order: order})
+	m.mu.Unlock()
+
+	m.deliver()
+	m.poke()
+	return id, nil
+}
+
+// Track manages an order placed elsewhere. It is reported as accepted when first
+// polled, and any amount it has traded by then as a fill.
+func (m *OrderManager) Track(symbol string, id uint64) {
+	m.mu.Lock()
+	if _, ok := m.orders[id]; !ok {
+		m.orders[id] = &managedOrder{order: Order{Id: id, Symbol: symbol}, interval: m.minInterval, next: time.Now()}
+	}
+	m.mu.Unlock()
+	m.poke()
+}
+
+// Cancel asks zb to cancel an order. OrderCancelled follows once a poll or an update
+// shows the order cancelled; it may still fill in the meantime.
+func (m *OrderManager) Cancel(symbol string, id uint64) error {
+	if err := m.trading.CancelOrder(symbol, id, m.accessKey, m.secretKey); err != nil {
+		return err
+	}
+	m.pollSoon(func(order Order) bool { return order.Id == id })
+	return nil
+}
+
+// Orders returns the orders not yet filled or cancelled, oldest first.
+func (m *OrderManager) Orders() []Order {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var orders []Order
+	for _, o := range m.orders {
+		orders = append(orders, o.order)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].Id < orders[j].Id })
+	return orders
+}
+
+// Update applies a fresh state of a managed order, e.g. from a private data feed.
+// States older than the one known are ignored.
+func (m *OrderManager) Update(order Order) {
+	m.mu.Lock()
+	o, ok := m.orders[order.Id]
+	if !ok {
+		m.mu.Unlock()
+		return
+	}
+	if m.apply(o, order) {
+		o.interval, o.next = m.minInterval, time.Now().Add(m.minInterval)
+	}
+	m.mu.Unlock()
+
+	m.deliver()
+}
+
+// Follow polls the orders of symbol as soon as the trade tape trades at or through their price.
+func (m *OrderManager) Follow(ws Streamer, symbol string) {
+	ws.SubscribeTrades(symbol, func(trades []Trade) {
+		m.pollSoon(func(order Order) bool {
+			if order.Symbol != symbol {
+				return false
+			}
+			for _, t := range trades {
+				if order.TradeType == Buy && t.Price <= order.Price || order.TradeType == Sell && t.Price >= order.Price {
+					return true
+				}
+			}
+			return false
+		})
+	})
+}
+
+// Run polls managed orders until ctx is done.
+func (m *OrderManager) Run(ctx context.Context) error {
+	for {
+		wait := m.pollDue()
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-m.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// pollDue polls every order whose time has come and returns how long to wait for the next one.
+func (m *OrderManager) pollDue() time.Duration {
+	m.mu.Lock()
+	now := time.Now()
+	var due []Order
+	for _, o := range m.orders {
+		if !o.next.After(now) {
+			due = append(due, o.order)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, order := range due {
+		fresh, err := m.trading.GetOrder(order.Symbol, order.Id, m.accessKey, m.secretKey)
+
+		m.mu.Lock()
+		o, ok := m.orders[order.Id]
+		if !ok {
+			m.mu.Unlock()
+			continue
+		}
+		if err == nil && m.apply(o, fresh) {
+			o.interval = m.minInterval
+		} else {
+			o.interval *= 2
+			if o.interval > m.maxInterval {
+				o.interval = m.maxInterval
+			}
+		}
+		o.next = time.Now().Add(o.interval)
+		m.mu.Unlock()
+
+		m.deliver()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	wait := m.maxInterval
+	now = time.Now()
+	for _, o := range m.orders {
+		if d := o.next.Sub(now); d < wait {
+			wait = d
+		}
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
+
+// apply moves o to order, queues the events of the transition and reports whether
+// there were any. m.mu must be held.
+func (m *OrderManager) apply(o *managedOrder, order Order) bool {
+	previous := o.order
+	if o.seen && order.TradeAmount < previous.TradeAmount-epsilon {
+		return false
+	}
+
+	var events []OrderEvent
+	if !o.seen {
+		o.seen = true
+		events = append(events, OrderEvent{Type: OrderAccepted, Order: order})
+	}
+
+	if filled := order.TradeAmount - previous.TradeAmount; filled > epsilon {
+		money := tradeMoney(order) - tradeMoney(previous)
+		event := OrderEvent{Type: OrderPartiallyFilled, Order: order, FillAmount: filled, FillPrice: money / filled}
+		if order.Status == Finished {
+			event.Type = OrderFilled
+		}
+		events = append(events, event)
+	} else if order.Status == Finished && previous.Status != Finished {
+		events = append(events, OrderEvent{Type: OrderFilled, Order: order})
+	}
+
+	if order.Status == Cancelled && previous.Status != Cancelled {
+		events = append(events, OrderEvent{Type: OrderCancelled, Order: order})
+	}
+
+	o.order = order
+	if order.Status == Finished || order.Status == Cancelled {
+		delete(m.orders, order.Id)
+	}
+	m.queue = append(m.queue, events...)
+	return len(events) > 0
+}
+
+// tradeMoney falls back to the average price for orders zb reports no trade money for.
+func tradeMoney(order Order) float64 {
+	if order.TradeMoney == 0 {
+		return order.Average * order.TradeAmount
+	}
+	return order.TradeMoney
+}
+
+func (m *OrderManager) pollSoon(match func(order Order) bool) {
+	m.mu.Lock()
+	now := time.Now()
+	for _, o := range m.orders {
+		if match(o.order) {
+			o.interval, o.next = m.minInterval, now
+		}
+	}
+	m.mu.Unlock()
+	m.poke()
+}
+
+func (m *OrderManager) poke() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliver hands queued events to the handler. Events are queued under m.mu, so they are
+// in order, and only one goroutine delivers at a time, so the handler is never run
+// twice at once and may itself cause new events.
+func (m *OrderManager) deliver() {
+	m.mu.Lock()
+	if m.delivering {
+		m.mu.Unlock()
+		return
+	}
+	m.delivering = true
+	for len(m.queue) > 0 {
+		event := m.queue[0]
+		m.queue = m.queue[1:]
+		m.mu.Unlock()
+		m.handler(event)
+		m.mu.Lock()
+	}
+	m.delivering = false
+	m.mu.Unlock()
+}
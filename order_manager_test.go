@@ -0,0 +1,96 @@
+package zb
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestOrderManager_Run(t *testing.T) {
+	paper := NewPaperClient(map[string]float64{"usdt": 10000}, 0)
+	events := make(chan OrderEvent, 16)
+	m := NewOrderManager(paper, "", "", func(event OrderEvent) {
+		events <- event
+	})
+	m.SetPollIntervals(10*time.Millisecond, 50*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+
+	_, err := m.Place("btc_usdt", 0, 1, Buy)
+	assert.Equal(t, InvalidPrice, err.(*ApiError).Code)
+	assert.Equal(t, OrderRejected, nextEvent(t, events).Type)
+
+	id, err := m.Place("btc_usdt", 10000, 0.5, Buy)
+	assert.Nil(t, err)
+	assert.Equal(t, OrderAccepted, nextEvent(t, events).Type)
+
+	paper.OnDepth("btc_usdt", Depth{Asks: []DepthEntry{{Price: 9900, Volume: 0.2}}})
+	event := nextEvent(t, events)
+	assert.Equal(t, OrderPartiallyFilled, event.Type)
+	assert.InDelta(t, 0.2, event.FillAmount, epsilon)
+	assert.InDelta(t, 9900, event.FillPrice, epsilon)
+
+	paper.OnDepth("btc_usdt", Depth{Asks: []DepthEntry{{Price: 9950, Volume: 0.1}, {Price: 9990, Volume: 0.1}}})
+	event = nextEvent(t, events)
+	assert.Equal(t, OrderPartiallyFilled, event.Type)
+	assert.InDelta(t, 0.2, event.FillAmount, epsilon)
+	assert.InDelta(t, 9970, event.FillPrice, epsilon)
+	assert.InDelta(t, 0.4, event.Order.TradeAmount, epsilon)
+
+	assert.Nil(t, m.Cancel("btc_usdt", id))
+	event = nextEvent(t, events)
+	assert.Equal(t, OrderCancelled, event.Type)
+	assert.Empty(t, m.Orders())
+}
+
+func TestOrderManager_PlaceUnknown(t *testing.T) {
+	trading := &failingTrading{PaperClient: NewPaperClient(map[string]float64{"usdt": 10000}, 0), errs: []error{
+		&ApiError{Code: GeneralError, Message: "Malformed response"},
+	}}
+	var events []OrderEvent
+	m := NewOrderManager(trading, "", "", func(event OrderEvent) {
+		events = append(events, event)
+	})
+
+	// the order may have been placed, so it is not reported as rejected
+	_, err := m.Place("btc_usdt", 10000, 0.5, Buy)
+	assert.Equal(t, GeneralError, err.(*ApiError).Code)
+	assert.Empty(t, events)
+}
+
+func TestOrderManager_Update(t *testing.T) {
+	paper := NewPaperClient(map[string]float64{"btc": 1}, 0)
+	id, _ := paper.PlaceOrder("btc_usdt", 10000, 1, Sell, "", "")
+
+	var events []OrderEvent
+	m := NewOrderManager(paper, "", "", func(event OrderEvent) {
+		events = append(events, event)
+	})
+	m.Track("btc_usdt", id)
+	m.Update(Order{Id: id, Symbol: "btc_usdt", Price: 10000, TotalAmount: 1, TradeAmount: 0.6, TradeMoney: 6000, Status: PartiallyFilled, TradeType: Sell})
+	m.Update(Order{Id: id, Symbol: "btc_usdt", Price: 10000, TotalAmount: 1, TradeAmount: 0.5, TradeMoney: 5000, Status: PartiallyFilled, TradeType: Sell})
+	m.Update(Order{Id: id, Symbol: "btc_usdt", Price: 10000, TotalAmount: 1, TradeAmount: 1, Average: 10100, Status: Finished, TradeType: Sell})
+
+	if assert.Len(t, events, 3) {
+		assert.Equal(t, OrderAccepted, events[0].Type)
+		assert.Equal(t, OrderPartiallyFilled, events[1].Type)
+		assert.InDelta(t, 0.6, events[1].FillAmount, epsilon)
+		assert.Equal(t, OrderFilled, events[2].Type)
+		assert.InDelta(t, 0.4, events[2].FillAmount, epsilon)
+		assert.InDelta(t, (10100*1-6000)/0.4, events[2].FillPrice, 1e-6)
+	}
+	assert.Empty(t, m.Orders())
+}
+
+func nextEvent(t *testing.T, events chan OrderEvent) OrderEvent {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("No order event")
+		return OrderEvent{}
+	}
+}
@@ -0,0 +1,481 @@
+package zb
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const epsilon = 1e-9
+
+// PaperClient simulates the trading side of RestClient. Orders are matched against the
+// Depth, Trade and Kline data passed to OnDepth, OnTrades and OnKline, which may come
+// from a live WebSocketClient (see Follow), a Replayer or any other source. Credentials
+// are ignored.
+type PaperClient struct {
+	mu       sync.Mutex
+	fee      float64
+	slippage float64
+	latency  uint64
+	clock    func() uint64
+	assets   map[string]*Asset
+	fees     map[string]float64
+	orders   map[uint64]*Order
+	ids      []uint64
+	nextId   uint64
+	depths   map[string]Depth
+	consumed map[string]map[float64]float64
+	cancels  map[uint64]uint64
+	fills    []Fill
+}
+
+// Fill is one execution of a paper order. Fee is charged in FeeCoin, the coin received.
+type Fill struct {
+	OrderId   uint64
+	Symbol    string
+	TradeType TradeType
+	Price     float64
+	Amount    float64
+	Fee       float64
+	FeeCoin   string
+	Time      uint64
+}
+
+// NewPaperClient creates a simulated account holding balances, keyed by coin, e.g. "usdt".
+// fee is the rate charged on the coin received by every fill.
+func NewPaperClient(balances map[string]float64, fee float64) *PaperClient {
+	c := &PaperClient{
+		fee:      fee,
+		clock:    nowMillis,
+		assets:   map[string]*Asset{},
+		fees:     map[string]float64{},
+		orders:   map[uint64]*Order{},
+		nextId:   1,
+		depths:   map[string]Depth{},
+		consumed: map[string]map[float64]float64{},
+		cancels:  map[uint64]uint64{},
+	}
+	for coin, balance := range balances {
+		c.asset(coin).Available = balance
+	}
+	return c
+}
+
+// SetClock replaces the wall clock used to stamp orders, e.g. with simulated time.
+func (c *PaperClient) SetClock(clock func() uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clock = clock
+}
+
+// SetSlippage makes orders that take liquidity fill rate worse than the quoted price,
+// e.g. 0.001 for 10 basis points, though never worse than their limit price.
+func (c *PaperClient) SetSlippage(rate float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.slippage = rate
+}
+
+// SetLatency delays by latency, on the clock, when new orders can fill and when
+// cancels take effect. An order may still fill while its cancel is in flight.
+func (c *PaperClient) SetLatency(latency time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.latency = uint64(latency / time.Millisecond)
+}
+
+// Follow feeds the depth and trades of symbol pushed by a streamer into the simulation.
+func (c *PaperClient) Follow(ws Streamer, symbol string) {
+	ws.SubscribeDepth(symbol, func(depth Depth) {
+		c.OnDepth(symbol, depth)
+	})
+	ws.SubscribeTrades(symbol, func(trades []Trade) {
+		c.OnTrades(symbol, trades)
+	})
+}
+
+func (c *PaperClient) GetAccount(accessKey string, secretKey string) (Account, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.settle()
+
+	var keys []string
+	for key := range c.assets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var assets []Asset
+	for _, key := range keys {
+		assets = append(assets, *c.assets[key])
+	}
+	return Account{Username: "paper", Assets: assets}, nil
+}
+
+// Fees returns the total fees paid per coin.
+func (c *PaperClient) Fees() map[string]float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fees := map[string]float64{}
+	for coin, fee := range c.fees {
+		fees[coin] = fee
+	}
+	return fees
+}
+
+// Fills returns every execution so far, oldest first.
+func (c *PaperClient) Fills() []Fill {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Fill(nil), c.fills...)
+}
+
+func (c *PaperClient) PlaceOrder(symbol string, price, amount float64, tradeType TradeType, accessKey, secretKey string) (uint64, error) {
+	base, quote, ok := splitSymbol(symbol)
+	if !ok || (tradeType != Buy && tradeType != Sell) {
+		return 0, &ApiError{Code: InvalidArgument, Message: "Invalid symbol or trade type"}
+	}
+	if price <= 0 {
+		return 0, &ApiError{Code: InvalidPrice, Message: "Price must be positive"}
+	}
+	if amount <= 0 {
+		return 0, &ApiError{Code: InvalidAmount, Message: "Amount must be positive"}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.settle()
+
+	coin, frozen := base, amount
+	if tradeType == Buy {
+		coin, frozen = quote, price*amount
+	}
+	asset := c.asset(coin)
+	if asset.Available+epsilon < frozen {
+		return 0, &ApiError{Code: InsufficientFund, Message: "Insufficient " + coin}
+	}
+	asset.Available -= frozen
+	asset.Freeze += frozen
+
+	order := &Order{Id: c.nextId, Price: price, TotalAmount: amount, Symbol: symbol, Status: Pending, TradeType: tradeType, Time: c.clock()}
+	c.nextId++
+	c.orders[order.Id] = order
+	c.ids = append(c.ids, order.Id)
+
+	if depth, ok := c.depths[symbol]; ok && c.latency == 0 {
+		c.matchDepth(order, depth)
+	}
+	return order.Id, nil
+}
+
+func (c *PaperClient) CancelOrder(symbol string, id uint64, accessKey, secretKey string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.settle()
+
+	order, ok := c.orders[id]
+	if !ok || order.Symbol != symbol || order.Status == Finished || order.Status == Cancelled {
+		return &ApiError{Code: OrderNotFound, Message: "Order not found"}
+	}
+	if _, ok := c.cancels[id]; !ok {
+		c.cancels[id] = c.clock() + c.latency
+	}
+	c.settle()
+	return nil
+}
+
+// settle carries out the cancels whose latency has passed.
+func (c *PaperClient) settle() {
+	if len(c.cancels) == 0 {
+		return
+	}
+	now := c.clock()
+	for id, at := range c.cancels {
+		if at > now {
+			continue
+		}
+		delete(c.cancels, id)
+		if order := c.orders[id]; order.Status == Pending || order.Status == PartiallyFilled {
+			c.cancel(order)
+		}
+	}
+}
+
+func (c *PaperClient) cancel(order *Order) {
+	base, quote, _ := splitSymbol(order.Symbol)
+	remaining := order.TotalAmount - order.TradeAmount
+	if order.TradeType == Buy {
+		c.unfreeze(quote, remaining*order.Price)
+	} else {
+		c.unfreeze(base, remaining)
+	}
+	order.Status = Cancelled
+}
+
+func (c *PaperClient) GetOrder(symbol string, id uint64, accessKey, secretKey string) (Order, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.settle()
+
+	order, ok := c.orders[id]
+	if !ok || order.Symbol != symbol {
+		return Order{}, &ApiError{Code: OrderNotFound, Message: "Order not found"}
+	}
+	return *order, nil
+}
+
+// GetOrders pages through orders newest first, starting at page 1, like zb does.
+func (c *PaperClient) GetOrders(symbol string, tradeType TradeType, page uint64, size uint16, accessKey, secretKey string) ([]Order, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.settle()
+
+	var matched []Order
+	for i := len(c.ids) - 1; i >= 0; i-- {
+		order := c.orders[c.ids[i]]
+		if order.Symbol == symbol && (tradeType == All || order.TradeType == tradeType) {
+			matched = append(matched, *order)
+		}
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	from := (page - 1) * uint64(size)
+	if size == 0 || from >= uint64(len(matched)) {
+		return []Order{}, &ApiError{Code: OrderNotFound, Message: "No orders"}
+	}
+	to := from + uint64(size)
+	if to > uint64(len(matched)) {
+		to = uint64(len(matched))
+	}
+	return matched[from:to], nil
+}
+
+func (c *PaperClient) GetOpenOrders(symbol string, accessKey, secretKey string) ([]Order, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.settle()
+
+	orders := []Order{}
+	for i := len(c.ids) - 1; i >= 0; i-- {
+		order := c.orders[c.ids[i]]
+		if order.Symbol == symbol && (order.Status == Pending || order.Status == PartiallyFilled) {
+			orders = append(orders, *order)
+		}
+	}
+	return orders, nil
+}
+
+// OnDepth matches open orders of symbol against a new order book snapshot. Each
+// snapshot level provides its volume once, whether to resting or to new orders.
+func (c *PaperClient) OnDepth(symbol string, depth Depth) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.settle()
+
+	asks := append([]DepthEntry(nil), depth.Asks...)
+	sort.Slice(asks, func(i, j int) bool { return asks[i].Price < asks[j].Price })
+	bids := append([]DepthEntry(nil), depth.Bids...)
+	sort.Slice(bids, func(i, j int) bool { return bids[i].Price > bids[j].Price })
+	depth = Depth{Asks: asks, Bids: bids, Time: depth.Time}
+
+	c.depths[symbol] = depth
+	c.consumed[symbol] = map[float64]float64{}
+	for _, order := range c.openOrders(symbol) {
+		c.matchDepth(order, depth)
+	}
+}
+
+// OnTrades fills resting orders of symbol at their own price when the tape trades
+// through them, sharing each trade's amount in price-time priority. Buy trades fill
+// sell orders and sell trades fill buy orders.
+func (c *PaperClient) OnTrades(symbol string, trades []Trade) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.settle()
+
+	for _, trade := range trades {
+		remaining := trade.Amount
+		for _, order := range c.openOrders(symbol) {
+			if remaining <= epsilon {
+				break
+			}
+			// a trade fills the side its aggressor took liquidity from
+			if order.TradeType == trade.TradeType {
+				continue
+			}
+			if order.TradeType == Buy && trade.Price > order.Price || order.TradeType == Sell && trade.Price < order.Price {
+				continue
+			}
+			q := minFloat(remaining, order.TotalAmount-order.TradeAmount)
+			c.fill(order, q, order.Price)
+			remaining -= q
+		}
+	}
+}
+
+// OnKline fills resting orders of symbol whose price the bar traded at, sharing the
+// bar's volume in price-time priority. Orders live when the bar opened and crossed by
+// its open price take liquidity at the open, any others fill at their own price.
+// kline.Time is the open time of a bar of period.
+func (c *PaperClient) OnKline(symbol string, period Period, kline Kline) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.settle()
+
+	remaining := kline.Volume
+	for _, order := range c.openOrders(symbol) {
+		if remaining <= epsilon {
+			break
+		}
+		if order.TradeType == Buy && kline.Low > order.Price || order.TradeType == Sell && kline.High < order.Price {
+			continue
+		}
+
+		price := order.Price
+		crossed := order.TradeType == Buy && kline.Open < order.Price || order.TradeType == Sell && kline.Open > order.Price
+		if crossed && order.Time+c.latency <= kline.Time {
+			price = c.slip(order, kline.Open)
+		}
+		q := minFloat(remaining, order.TotalAmount-order.TradeAmount)
+		c.fill(order, q, price)
+		remaining -= q
+	}
+}
+
+// openOrders returns unfinished orders of symbol past their latency, best priced first
+// and oldest first on ties.
+func (c *PaperClient) openOrders(symbol string) []*Order {
+	now := c.clock()
+	var orders []*Order
+	for _, id := range c.ids {
+		order := c.orders[id]
+		if order.Time+c.latency > now {
+			continue
+		}
+		if order.Symbol == symbol && (order.Status == Pending || order.Status == PartiallyFilled) {
+			orders = append(orders, order)
+		}
+	}
+	sort.SliceStable(orders, func(i, j int) bool {
+		a, b := orders[i], orders[j]
+		if a.TradeType != b.TradeType {
+			return a.TradeType == Buy
+		}
+		if a.TradeType == Buy {
+			return a.Price > b.Price
+		}
+		return a.Price < b.Price
+	})
+	return orders
+}
+
+func (c *PaperClient) matchDepth(order *Order, depth Depth) {
+	levels := depth.Asks
+	if order.TradeType == Sell {
+		levels = depth.Bids
+	}
+
+	consumed := c.consumed[order.Symbol]
+	if consumed == nil {
+		consumed = map[float64]float64{}
+		c.consumed[order.Symbol] = consumed
+	}
+
+	for _, level := range levels {
+		remaining := order.TotalAmount - order.TradeAmount
+		if remaining <= epsilon {
+			return
+		}
+		if order.TradeType == Buy && level.Price > order.Price || order.TradeType == Sell && level.Price < order.Price {
+			return
+		}
+		available := level.Volume - consumed[level.Price]
+		if available <= epsilon {
+			continue
+		}
+		q := minFloat(remaining, available)
+		consumed[level.Price] += q
+		c.fill(order, q, c.slip(order, level.Price))
+	}
+}
+
+// slip moves a taker price against the order by the slippage rate, up to its limit.
+func (c *PaperClient) slip(order *Order, price float64) float64 {
+	if order.TradeType == Buy {
+		return minFloat(price*(1+c.slippage), order.Price)
+	}
+	return maxFloat(price*(1-c.slippage), order.Price)
+}
+
+func (c *PaperClient) fill(order *Order, amount float64, price float64) {
+	base, quote, _ := splitSymbol(order.Symbol)
+	f := Fill{OrderId: order.Id, Symbol: order.Symbol, TradeType: order.TradeType, Price: price, Amount: amount, Time: c.clock()}
+	if order.TradeType == Buy {
+		frozen := c.asset(quote)
+		frozen.Freeze -= amount * order.Price
+		frozen.Available += amount * (order.Price - price)
+		f.Fee, f.FeeCoin = c.receive(base, amount), base
+	} else {
+		c.asset(base).Freeze -= amount
+		f.Fee, f.FeeCoin = c.receive(quote, amount*price), quote
+	}
+	c.fills = append(c.fills, f)
+
+	order.TradeAmount += amount
+	order.TradeMoney += amount * price
+	order.Average = order.TradeMoney / order.TradeAmount
+	if order.TotalAmount-order.TradeAmount <= epsilon {
+		order.Status = Finished
+	} else {
+		order.Status = PartiallyFilled
+	}
+}
+
+func (c *PaperClient) receive(coin string, amount float64) float64 {
+	fee := amount * c.fee
+	c.asset(coin).Available += amount - fee
+	c.fees[coin] += fee
+	return fee
+}
+
+func (c *PaperClient) unfreeze(coin string, amount float64) {
+	asset := c.asset(coin)
+	asset.Freeze -= amount
+	asset.Available += amount
+}
+
+func (c *PaperClient) asset(coin string) *Asset {
+	asset, ok := c.assets[coin]
+	if !ok {
+		asset = &Asset{Coin: Coin{CnName: strings.ToUpper(coin), EnName: strings.ToUpper(coin), Key: coin}}
+		c.assets[coin] = asset
+	}
+	return asset
+}
+
+// splitSymbol splits a market such as btc_usdt into its base and quote coins.
+func splitSymbol(symbol string) (string, string, bool) {
+	parts := strings.Split(symbol, "_")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,127 @@
+package zb
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPaperClient_PlaceOrder(t *testing.T) {
+	c := NewPaperClient(map[string]float64{"usdt": 1000}, 0.002)
+
+	_, err := c.PlaceOrder("btc_usdt", 10000, 1, Buy, "", "")
+	assert.Equal(t, InsufficientFund, err.(*ApiError).Code)
+
+	id, err := c.PlaceOrder("btc_usdt", 10000, 0.05, Buy, "", "")
+	assert.Nil(t, err)
+
+	account, _ := c.GetAccount("", "")
+	assert.Equal(t, []Asset{{Freeze: 500, Available: 500, Coin: Coin{CnName: "USDT", EnName: "USDT", Key: "usdt"}}}, account.Assets)
+
+	order, _ := c.GetOrder("btc_usdt", id, "", "")
+	assert.Equal(t, Pending, order.Status)
+}
+
+func TestPaperClient_OnDepth(t *testing.T) {
+	c := NewPaperClient(map[string]float64{"usdt": 1000}, 0.002)
+	id, _ := c.PlaceOrder("btc_usdt", 10000, 0.05, Buy, "", "")
+
+	c.OnDepth("btc_usdt", Depth{Asks: []DepthEntry{{Price: 10100, Volume: 1}, {Price: 9900, Volume: 0.02}}})
+	order, _ := c.GetOrder("btc_usdt", id, "", "")
+	assert.Equal(t, PartiallyFilled, order.Status)
+	assert.InDelta(t, 0.02, order.TradeAmount, epsilon)
+	assert.InDelta(t, 9900, order.Average, epsilon)
+
+	c.OnDepth("btc_usdt", Depth{Asks: []DepthEntry{{Price: 9950, Volume: 1}}})
+	order, _ = c.GetOrder("btc_usdt", id, "", "")
+	assert.Equal(t, Finished, order.Status)
+	assert.InDelta(t, (0.02*9900+0.03*9950)/0.05, order.Average, epsilon)
+
+	account, _ := c.GetAccount("", "")
+	assert.InDelta(t, 0.05*0.998, account.Assets[0].Available, epsilon)
+	assert.InDelta(t, 1000-0.02*9900-0.03*9950, account.Assets[1].Available, epsilon)
+	assert.InDelta(t, 0, account.Assets[1].Freeze, epsilon)
+	assert.InDelta(t, 0.05*0.002, c.Fees()["btc"], epsilon)
+}
+
+func TestPaperClient_OnTrades(t *testing.T) {
+	c := NewPaperClient(map[string]float64{"btc": 1}, 0)
+	first, _ := c.PlaceOrder("btc_usdt", 11000, 0.5, Sell, "", "")
+	second, _ := c.PlaceOrder("btc_usdt", 10900, 0.5, Sell, "", "")
+
+	// a sell aggressor does not fill resting sells
+	c.OnTrades("btc_usdt", []Trade{{Price: 10950, Amount: 0.7, TradeType: Sell}})
+	order, _ := c.GetOrder("btc_usdt", second, "", "")
+	assert.Equal(t, Pending, order.Status)
+
+	c.OnTrades("btc_usdt", []Trade{{Price: 10950, Amount: 0.7, TradeType: Buy}})
+	order, _ = c.GetOrder("btc_usdt", second, "", "")
+	assert.Equal(t, Finished, order.Status)
+	order, _ = c.GetOrder("btc_usdt", first, "", "")
+	assert.Equal(t, Pending, order.Status)
+
+	c.OnTrades("btc_usdt", []Trade{{Price: 11000, Amount: 0.2, TradeType: Buy}})
+	order, _ = c.GetOrder("btc_usdt", first, "", "")
+	assert.Equal(t, PartiallyFilled, order.Status)
+
+	assert.Nil(t, c.CancelOrder("btc_usdt", first, "", ""))
+	order, _ = c.GetOrder("btc_usdt", first, "", "")
+	assert.Equal(t, Cancelled, order.Status)
+	assert.NotNil(t, c.CancelOrder("btc_usdt", first, "", ""))
+
+	account, _ := c.GetAccount("", "")
+	assert.InDelta(t, 0.3, account.Assets[0].Available, epsilon)
+	assert.InDelta(t, 0, account.Assets[0].Freeze, epsilon)
+	assert.InDelta(t, 0.5*10900+0.2*11000, account.Assets[1].Available, epsilon)
+
+	orders, _ := c.GetOrders("btc_usdt", All, 1, 10, "", "")
+	assert.Equal(t, []uint64{second, first}, []uint64{orders[0].Id, orders[1].Id})
+}
+
+func TestPaperClient_OnKline(t *testing.T) {
+	c := NewPaperClient(map[string]float64{"usdt": 10000}, 0)
+	c.SetSlippage(0.01)
+	c.SetClock(func() uint64 { return 0 })
+	first, _ := c.PlaceOrder("btc_usdt", 100, 1, Buy, "", "")
+	second, _ := c.PlaceOrder("btc_usdt", 90, 1, Buy, "", "")
+
+	c.OnKline("btc_usdt", OneMinute, Kline{Time: 0, Open: 95, High: 96, Low: 94, Close: 95, Volume: 10})
+	order, _ := c.GetOrder("btc_usdt", first, "", "")
+	assert.Equal(t, Finished, order.Status)
+	assert.InDelta(t, 95*1.01, order.Average, epsilon)
+	order, _ = c.GetOrder("btc_usdt", second, "", "")
+	assert.Equal(t, Pending, order.Status)
+
+	c.OnKline("btc_usdt", OneMinute, Kline{Time: 60000, Open: 95, High: 96, Low: 89, Close: 95, Volume: 0.4})
+	order, _ = c.GetOrder("btc_usdt", second, "", "")
+	assert.Equal(t, PartiallyFilled, order.Status)
+	assert.InDelta(t, 0.4, order.TradeAmount, epsilon)
+	assert.InDelta(t, 90, order.Average, epsilon)
+	assert.Len(t, c.Fills(), 2)
+}
+
+func TestPaperClient_SetLatency(t *testing.T) {
+	now := uint64(1000)
+	c := NewPaperClient(map[string]float64{"btc": 1}, 0)
+	c.SetClock(func() uint64 { return now })
+	c.SetLatency(500 * time.Millisecond)
+
+	id, _ := c.PlaceOrder("btc_usdt", 100, 1, Sell, "", "")
+	c.OnTrades("btc_usdt", []Trade{{Price: 101, Amount: 0.5, TradeType: Buy}})
+	order, _ := c.GetOrder("btc_usdt", id, "", "")
+	assert.Equal(t, Pending, order.Status)
+
+	now = 1500
+	c.OnTrades("btc_usdt", []Trade{{Price: 101, Amount: 0.5, TradeType: Buy}})
+	assert.Nil(t, c.CancelOrder("btc_usdt", id, "", ""))
+	c.OnTrades("btc_usdt", []Trade{{Price: 101, Amount: 0.2, TradeType: Buy}})
+	order, _ = c.GetOrder("btc_usdt", id, "", "")
+	assert.Equal(t, PartiallyFilled, order.Status)
+	assert.InDelta(t, 0.7, order.TradeAmount, epsilon)
+
+	now = 2000
+	order, _ = c.GetOrder("btc_usdt", id, "", "")
+	assert.Equal(t, Cancelled, order.Status)
+	account, _ := c.GetAccount("", "")
+	assert.InDelta(t, 0.3, account.Assets[0].Available, epsilon)
+}
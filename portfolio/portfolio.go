@@ -0,0 +1,361 @@
+// Package portfolio keeps positions, average cost and P&L of an account from its fills,
+// marked to the latest quotes. Positions are kept per market, in the quote coin of the
+// market, and fees count into the average cost of buys and the proceeds of sells.
+package portfolio
+
+import (
+	"encoding/csv"
+	"github.com/berryland/zb"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const epsilon = 1e-9
+
+// Position is what the fills of one market add up to. Amount is negative when more was
+// sold than bought. Average is the cost per coin of Amount, fees included, and Realized
+// and Unrealized are in Quote. Balance is the total of Coin in the last account snapshot,
+// which also counts coins that came from deposits or other markets.
+type Position struct {
+	Symbol     string
+	Coin       string
+	Quote      string
+	Amount     float64
+	Average    float64
+	Bought     float64
+	Sold       float64
+	Mark       float64
+	Realized   float64
+	Unrealized float64
+	// Fees are in Quote, fees paid in Coin are valued at their fill price
+	Fees    float64
+	Balance float64
+}
+
+// Day is the activity of one market over a day. Unrealized is as of the last fill or
+// mark of the day, and PnL is Realized plus the change of Unrealized since the previous
+// Day of the market.
+type Day struct {
+	Date       string
+	Symbol     string
+	Bought     float64
+	Sold       float64
+	Fees       float64
+	Realized   float64
+	Unrealized float64
+	PnL        float64
+}
+
+type orderProgress struct {
+	amount float64
+	money  float64
+}
+
+type Portfolio struct {
+	mu        sync.Mutex
+	feeRate   float64
+	location  *time.Location
+	clock     func() uint64
+	positions map[string]*Position
+	orders    map[uint64]orderProgress
+	fees      map[string]float64
+	balances  map[string]float64
+	days      map[string]map[string]*Day
+}
+
+// New creates an empty portfolio. feeRate is charged on the coin received by fills
+// derived from orders, which carry no fee, and days start at midnight of location, UTC
+// when nil.
+func New(feeRate float64, location *time.Location) *Portfolio {
+	if location == nil {
+		location = time.UTC
+	}
+	return &Portfolio{
+		feeRate:   feeRate,
+		location:  location,
+		clock:     func() uint64 { return uint64(time.Now().UnixNano() / int64(time.Millisecond)) },
+		positions: map[string]*Position{},
+		orders:    map[uint64]orderProgress{},
+		fees:      map[string]float64{},
+		balances:  map[string]float64{},
+		days:      map[string]map[string]*Day{},
+	}
+}
+
+// SetClock replaces the wall clock AddOrder dates fills with, e.g. with simulated time.
+func (p *Portfolio) SetClock(clock func() uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clock = clock
+}
+
+// AddFill applies one execution, e.g. a zb.Fill of a PaperClient.
+func (p *Portfolio) AddFill(fill zb.Fill) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.apply(fill)
+}
+
+// AddOrder applies what order traded since it was last seen, so orders from GetOrders
+// and from OrderManager events can be added as often as they come. zb reports no time
+// of fills, so the fill is dated when it is first seen.
+func (p *Portfolio) AddOrder(order zb.Order) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	seen := p.orders[order.Id]
+	money := order.TradeMoney
+	if money == 0 {
+		money = order.Average * order.TradeAmount
+	}
+	amount := order.TradeAmount - seen.amount
+	if amount <= epsilon {
+		return
+	}
+	p.orders[order.Id] = orderProgress{amount: order.TradeAmount, money: money}
+
+	price := (money - seen.money) / amount
+	fill := zb.Fill{OrderId: order.Id, Symbol: order.Symbol, TradeType: order.TradeType, Price: price, Amount: amount, Time: p.clock()}
+	coin, quote := split(order.Symbol)
+	if order.TradeType == zb.Buy {
+		fill.Fee, fill.FeeCoin = amount*p.feeRate, coin
+	} else {
+		fill.Fee, fill.FeeCoin = amount*price*p.feeRate, quote
+	}
+	p.apply(fill)
+}
+
+// OnOrderEvent adds the order of event, it can serve as the handler of an OrderManager.
+func (p *Portfolio) OnOrderEvent(event zb.OrderEvent) {
+	p.AddOrder(event.Order)
+}
+
+// SetAccount takes the balances of account as the Balance of positions.
+func (p *Portfolio) SetAccount(account zb.Account) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.balances = map[string]float64{}
+	for _, asset := range account.Assets {
+		p.balances[asset.Coin.Key] = asset.Total()
+	}
+}
+
+func (p *Portfolio) Follow(ws zb.Streamer, symbol string) {
+	ws.SubscribeQuote(symbol, func(quote zb.Quote) {
+		p.OnQuote(symbol, quote)
+	})
+}
+
+// OnQuote marks symbol to the last price of quote.
+func (p *Portfolio) OnQuote(symbol string, quote zb.Quote) {
+	p.SetMark(symbol, quote.Last, quote.Time)
+}
+
+// OnDepth marks symbol to the mid price of depth, dated in seconds as zb does.
+func (p *Portfolio) OnDepth(symbol string, depth zb.Depth) {
+	ask, bid := 0.0, 0.0
+	for _, e := range depth.Asks {
+		if ask == 0 || e.Price < ask {
+			ask = e.Price
+		}
+	}
+	for _, e := range depth.Bids {
+		bid = math.Max(bid, e.Price)
+	}
+	if ask > 0 && bid > 0 {
+		p.SetMark(symbol, (ask+bid)/2, depth.Time*1000)
+	}
+}
+
+// SetMark values the position of symbol at price, as of t in milliseconds, 0 for now.
+func (p *Portfolio) SetMark(symbol string, price float64, t uint64) {
+	if price <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	position := p.position(symbol)
+	position.Mark = price
+	position.Unrealized = (price - position.Average) * position.Amount
+	p.day(symbol, t).Unrealized = position.Unrealized
+}
+
+// Positions returns the positions by market, in symbol order.
+func (p *Portfolio) Positions() []Position {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var positions []Position
+	for _, position := range p.positions {
+		snapshot := *position
+		snapshot.Balance = p.balances[snapshot.Coin]
+		positions = append(positions, snapshot)
+	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i].Symbol < positions[j].Symbol })
+	return positions
+}
+
+// Fees returns the fees paid, keyed by the coin they were paid in.
+func (p *Portfolio) Fees() map[string]float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	fees := map[string]float64{}
+	for coin, fee := range p.fees {
+		fees[coin] = fee
+	}
+	return fees
+}
+
+// Daily returns a Day for every day and market with fills or marks, by date and symbol.
+func (p *Portfolio) Daily() []Day {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var days []Day
+	for _, bySymbol := range p.days {
+		for _, day := range bySymbol {
+			days = append(days, *day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool {
+		if days[i].Symbol != days[j].Symbol {
+			return days[i].Symbol < days[j].Symbol
+		}
+		return days[i].Date < days[j].Date
+	})
+
+	previous := 0.0
+	for i := range days {
+		if i == 0 || days[i].Symbol != days[i-1].Symbol {
+			previous = 0
+		}
+		days[i].PnL = days[i].Realized + days[i].Unrealized - previous
+		previous = days[i].Unrealized
+	}
+	sort.SliceStable(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days
+}
+
+// WriteDailyCSV writes Daily as csv with a header row, amounts in the quote coin of each market.
+func (p *Portfolio) WriteDailyCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"date", "symbol", "bought", "sold", "fees", "realized", "unrealized", "pnl"})
+	for _, day := range p.Daily() {
+		writer.Write([]string{day.Date, day.Symbol, format(day.Bought), format(day.Sold), format(day.Fees), format(day.Realized), format(day.Unrealized), format(day.PnL)})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// format rounds value to 8 decimals, the scale of zb balances.
+func format(value float64) string {
+	return strconv.FormatFloat(math.Floor(value*1e8+0.5)/1e8, 'f', -1, 64)
+}
+
+// apply moves the position of fill.Symbol by average cost. p.mu must be held.
+func (p *Portfolio) apply(fill zb.Fill) {
+	position := p.position(fill.Symbol)
+	p.fees[fill.FeeCoin] += fill.Fee
+	fee := fill.Fee
+	if fill.FeeCoin == position.Coin {
+		fee *= fill.Price
+	}
+	position.Fees += fee
+
+	// the coins and their price per coin after fees
+	amount, price := fill.Amount, fill.Price
+	if fill.TradeType == zb.Buy {
+		position.Bought += fill.Amount
+		if fill.FeeCoin == position.Coin {
+			amount -= fill.Fee
+			price = fill.Price * fill.Amount / amount
+		} else {
+			price += fee / amount
+		}
+	} else {
+		position.Sold += fill.Amount
+		amount = -amount
+		price -= fee / fill.Amount
+	}
+
+	realized := 0.0
+	if position.Amount*amount >= 0 {
+		if total := position.Amount + amount; total != 0 {
+			position.Average = (position.Average*position.Amount + price*amount) / total
+		}
+	} else {
+		closed := math.Min(math.Abs(amount), math.Abs(position.Amount))
+		realized = (price - position.Average) * closed
+		if position.Amount < 0 {
+			realized = -realized
+		}
+		if math.Abs(amount) > math.Abs(position.Amount) {
+			position.Average = price
+		}
+	}
+	position.Amount += amount
+	if math.Abs(position.Amount) <= epsilon {
+		position.Amount, position.Average = 0, 0
+	}
+	position.Realized += realized
+	if position.Mark > 0 {
+		position.Unrealized = (position.Mark - position.Average) * position.Amount
+	}
+
+	day := p.day(fill.Symbol, fill.Time)
+	if fill.TradeType == zb.Buy {
+		day.Bought += fill.Amount
+	} else {
+		day.Sold += fill.Amount
+	}
+	day.Fees += fee
+	day.Realized += realized
+	day.Unrealized = position.Unrealized
+}
+
+// position returns the position of symbol, created when missing. p.mu must be held.
+func (p *Portfolio) position(symbol string) *Position {
+	position, ok := p.positions[symbol]
+	if !ok {
+		coin, quote := split(symbol)
+		position = &Position{Symbol: symbol, Coin: coin, Quote: quote}
+		p.positions[symbol] = position
+	}
+	return position
+}
+
+// day returns the Day of symbol containing t, in milliseconds. p.mu must be held.
+func (p *Portfolio) day(symbol string, t uint64) *Day {
+	at := time.Now()
+	if t > 0 {
+		at = time.Unix(0, int64(t)*int64(time.Millisecond))
+	}
+	date := at.In(p.location).Format("2006-01-02")
+
+	bySymbol, ok := p.days[date]
+	if !ok {
+		bySymbol = map[string]*Day{}
+		p.days[date] = bySymbol
+	}
+	day, ok := bySymbol[symbol]
+	if !ok {
+		day = &Day{Date: date, Symbol: symbol}
+		bySymbol[symbol] = day
+	}
+	return day
+}
+
+func split(symbol string) (string, string) {
+	i := strings.Index(symbol, "_")
+	if i < 0 {
+		return symbol, ""
+	}
+	return symbol[:i], symbol[i+1:]
+}
@@ -0,0 +1,81 @@
+package portfolio
+
+import (
+	"bytes"
+	"github.com/berryland/zb"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	firstDay  = uint64(1516579200000)
+	secondDay = firstDay + 86400000
+)
+
+func TestPortfolio(t *testing.T) {
+	p := New(0.002, time.UTC)
+	p.SetClock(func() uint64 { return secondDay })
+	p.AddFill(zb.Fill{OrderId: 1, Symbol: "btc_usdt", TradeType: zb.Buy, Price: 10000, Amount: 1, Fee: 0.002, FeeCoin: "btc", Time: firstDay})
+	p.SetMark("btc_usdt", 11000, firstDay+1000)
+
+	position := p.Positions()[0]
+	assert.InDelta(t, 0.998, position.Amount, epsilon)
+	assert.InDelta(t, 10000/0.998, position.Average, epsilon)
+	assert.InDelta(t, 20, position.Fees, epsilon)
+	assert.InDelta(t, 978, position.Unrealized, epsilon)
+
+	// placed on the first day, it fills on the second
+	order := zb.Order{Id: 2, Symbol: "btc_usdt", TradeType: zb.Sell, Price: 11000, TotalAmount: 0.998, TradeAmount: 0.5, TradeMoney: 5500, Status: zb.PartiallyFilled, Time: firstDay + 2000}
+	p.AddOrder(order)
+	p.OnOrderEvent(zb.OrderEvent{Type: zb.OrderPartiallyFilled, Order: order})
+	position = p.Positions()[0]
+	assert.InDelta(t, 0.498, position.Amount, epsilon)
+	assert.InDelta(t, 0.5*(10978-10000/0.998), position.Realized, epsilon)
+
+	order.TradeAmount, order.TradeMoney, order.Status = 0.998, 5500+0.498*12000, zb.Finished
+	p.OnOrderEvent(zb.OrderEvent{Type: zb.OrderFilled, Order: order})
+	p.SetAccount(zb.Account{Assets: []zb.Asset{{Available: 0.5, Coin: zb.Coin{Key: "btc"}}}})
+
+	position = p.Positions()[0]
+	assert.InDelta(t, 0, position.Amount, epsilon)
+	assert.InDelta(t, 1453.048, position.Realized, 1e-6)
+	assert.InDelta(t, 0, position.Unrealized, epsilon)
+	assert.InDelta(t, 1, position.Bought, epsilon)
+	assert.InDelta(t, 0.998, position.Sold, epsilon)
+	assert.InDelta(t, 0.5, position.Balance, epsilon)
+	assert.InDelta(t, 0.002, p.Fees()["btc"], epsilon)
+	assert.InDelta(t, 22.952, p.Fees()["usdt"], 1e-6)
+
+	days := p.Daily()
+	assert.Len(t, days, 2)
+	assert.Equal(t, "2018-01-22", days[0].Date)
+	assert.InDelta(t, 978, days[0].PnL, epsilon)
+	assert.Equal(t, "2018-01-23", days[1].Date)
+	assert.InDelta(t, 1453.048-978, days[1].PnL, 1e-6)
+
+	var buf bytes.Buffer
+	assert.Nil(t, p.WriteDailyCSV(&buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, "date,symbol,bought,sold,fees,realized,unrealized,pnl", lines[0])
+	assert.Equal(t, "2018-01-22,btc_usdt,1,0,20,0,978,978", lines[1])
+	assert.Len(t, lines, 3)
+}
+
+func TestPortfolio_short(t *testing.T) {
+	// days default to UTC
+	p := New(0, nil)
+	p.AddFill(zb.Fill{Symbol: "eth_usdt", TradeType: zb.Sell, Price: 1000, Amount: 2, Time: firstDay})
+	p.AddFill(zb.Fill{Symbol: "eth_usdt", TradeType: zb.Buy, Price: 900, Amount: 3, Time: firstDay})
+	p.OnDepth("eth_usdt", zb.Depth{Asks: []zb.DepthEntry{{Price: 951, Volume: 1}}, Bids: []zb.DepthEntry{{Price: 949, Volume: 1}}, Time: firstDay / 1000})
+
+	position := p.Positions()[0]
+	assert.InDelta(t, 1, position.Amount, epsilon)
+	assert.InDelta(t, 900, position.Average, epsilon)
+	assert.InDelta(t, 200, position.Realized, epsilon)
+	assert.InDelta(t, 50, position.Unrealized, epsilon)
+	days := p.Daily()
+	assert.Len(t, days, 1)
+	assert.Equal(t, "2018-01-22", days[0].Date)
+}
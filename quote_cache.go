@@ -0,0 +1,55 @@
+package zb
+
+import (
+	"sync"
+	"time"
+)
+
+// QuoteCache serves GetAllQuotes from memory and refreshes it at most once per ttl.
+type QuoteCache struct {
+	mu      sync.Mutex
+	market  MarketData
+	ttl     time.Duration
+	quotes  map[string]Quote
+	fetched time.Time
+}
+
+func NewQuoteCache(market MarketData, ttl time.Duration) *QuoteCache {
+	return &QuoteCache{market: market, ttl: ttl}
+}
+
+func (c *QuoteCache) GetAllQuotes() (map[string]Quote, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.quotes == nil || time.Since(c.fetched) >= c.ttl {
+		quotes, err := c.market.GetAllQuotes()
+		if err != nil {
+			return nil, err
+		}
+		c.quotes, c.fetched = quotes, time.Now()
+	}
+
+	quotes := make(map[string]Quote, len(c.quotes))
+	for market, quote := range c.quotes {
+		quotes[market] = quote
+	}
+	return quotes, nil
+}
+
+// GetQuote looks a market up by symbol, e.g. btc_usdt.
+func (c *QuoteCache) GetQuote(symbol string) (Quote, bool, error) {
+	quotes, err := c.GetAllQuotes()
+	if err != nil {
+		return Quote{}, false, err
+	}
+	quote, ok := quotes[symbol]
+	return quote, ok, nil
+}
+
+// Invalidate makes the next call fetch fresh quotes.
+func (c *QuoteCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.quotes = nil
+}
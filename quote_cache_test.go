@@ -0,0 +1,43 @@
+package zb_test
+
+import (
+	"github.com/berryland/zb"
+	"github.com/berryland/zb/zbtest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRestClientOffline_GetAllQuotes(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+
+	quotes, err := s.RestClient().GetAllQuotes()
+	assert.Nil(t, err)
+	assert.Len(t, quotes, 2)
+	assert.Equal(t, 11000.0, quotes["btc_usdt"].Last)
+	assert.Equal(t, 999.5, quotes["eth_usdt"].Buy)
+}
+
+func TestQuoteCache_GetQuote(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	cache := zb.NewQuoteCache(s.RestClient(), time.Hour)
+
+	quote, ok, err := cache.GetQuote("btc_usdt")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 11000.0, quote.Last)
+
+	s.SetQuote("btc_usdt", zb.Quote{Last: 12000})
+	quote, _, _ = cache.GetQuote("btc_usdt")
+	assert.Equal(t, 11000.0, quote.Last)
+	assert.Equal(t, 1, s.Requests("allTicker"))
+
+	cache.Invalidate()
+	quote, _, _ = cache.GetQuote("btc_usdt")
+	assert.Equal(t, 12000.0, quote.Last)
+
+	_, ok, _ = cache.GetQuote("ltc_usdt")
+	assert.False(t, ok)
+}
@@ -0,0 +1,432 @@
+// Package recorder persists the quote, depth, trade and kline streams of zb.com markets
+// into date partitioned CSV and Parquet files.
+//
+// Quotes, depth and trades come from the websocket API. After every reconnect the trades
+// missed while disconnected are fetched from the REST API, so the trade files hold every
+// trade exactly once. Klines are polled from the REST API once closed. Quotes and depth
+// are snapshots and are not filled in.
+//
+// The cursors only move past rows that survive a crash. CSV rows do once flushed, Parquet
+// rows only once their file is closed, so after a crash of a recorder writing Parquet the
+// CSV files may repeat the trades and klines since the last rotation. Trade ids and kline
+// times tell them apart. Files a crash left unfinished are finished on the next Run.
+package recorder
+
+import (
+	"context"
+	"github.com/berryland/zb"
+	"log"
+	"math"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	Dir     string
+	Symbols []string
+	// Formats defaults to CSV only
+	Formats []Format
+	// Period of the recorded klines, one minute by default
+	Period zb.Period
+	// Rotate is how long a file is written before the next one is opened, one hour by
+	// default and at most a day
+	Rotate time.Duration
+	// PollInterval is how often klines are polled and pending trade gap-fills retried
+	PollInterval   time.Duration
+	ReconnectDelay time.Duration
+}
+
+const (
+	defaultRotate         = time.Hour
+	defaultPollInterval   = 30 * time.Second
+	defaultReconnectDelay = 5 * time.Second
+	tradePageInterval     = 100 * time.Millisecond
+	fullTradePage         = 50
+	initialKlines         = 1000
+)
+
+type Recorder struct {
+	config      Config
+	market      zb.MarketData
+	dial        func() *zb.WebSocketClient
+	tradeCursor zb.TradeCursor
+	klineCursor zb.TradeCursor
+	now         func() time.Time
+	failed      chan error
+
+	mu         sync.Mutex
+	partitions map[string]*partition
+	lastTid    map[string]uint64
+	lastKline  map[string]uint64
+	// saved holds the cursor values last saved, by stream and symbol
+	saved map[string]uint64
+	// pending holds websocket trades back while the gap before them is being filled
+	pending map[string][]zb.Trade
+}
+
+// New records from market and from the websocket clients returned by dial, one per
+// connection attempt. The last recorded trade and kline of every symbol are kept in
+// Dir/cursors, so a restarted recorder carries on where the previous one stopped.
+func New(config Config, market zb.MarketData, dial func() *zb.WebSocketClient) *Recorder {
+	if len(config.Formats) == 0 {
+		config.Formats = []Format{CSV}
+	}
+	if config.Rotate <= 0 {
+		config.Rotate = defaultRotate
+	}
+	if config.Rotate > 24*time.Hour {
+		config.Rotate = 24 * time.Hour
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+	if config.ReconnectDelay <= 0 {
+		config.ReconnectDelay = defaultReconnectDelay
+	}
+
+	cursors := filepath.Join(config.Dir, "cursors")
+	return &Recorder{
+		config:      config,
+		market:      market,
+		dial:        dial,
+		tradeCursor: zb.NewFileCursor(filepath.Join(cursors, "trades")),
+		klineCursor: zb.NewFileCursor(filepath.Join(cursors, "klines-"+config.Period.String())),
+		now:         time.Now,
+		failed:      make(chan error, 1),
+		partitions:  map[string]*partition{},
+		lastTid:     map[string]uint64{},
+		lastKline:   map[string]uint64{},
+		saved:       map[string]uint64{},
+		pending:     map[string][]zb.Trade{},
+	}
+}
+
+// Run records until ctx is done, reconnecting whenever the websocket drops, and closes
+// every file before returning. Network and API errors are logged and retried; Run
+// returns early only when a file or cursor cannot be written.
+func (r *Recorder) Run(ctx context.Context) error {
+	err := r.run(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.partitions {
+		if cerr := p.close(); err == nil {
+			err = cerr
+		}
+	}
+	if serr := r.saveCursors(); err == nil {
+		err = serr
+	}
+	return err
+}
+
+func (r *Recorder) run(ctx context.Context) error {
+	if err := recoverParts(r.config.Dir); err != nil {
+		return err
+	}
+	for _, symbol := range r.config.Symbols {
+		tid, err := r.tradeCursor.Load(symbol)
+		if err != nil {
+			return err
+		}
+		kline, err := r.klineCursor.Load(symbol)
+		if err != nil {
+			return err
+		}
+		r.lastTid[symbol], r.lastKline[symbol] = tid, kline
+		r.saved[tradeStream.name+"/"+symbol], r.saved[klineStream.name+"/"+symbol] = tid, kline
+	}
+
+	for {
+		ws, closed, err := r.connect()
+		if err == nil {
+			err = r.record(ctx, closed)
+			ws.Disconnect()
+			if err != nil {
+				return err
+			}
+		} else {
+			log.Println("recorder: " + err.Error())
+		}
+
+		if !sleep(ctx, r.config.ReconnectDelay) {
+			return nil
+		}
+		log.Println("recorder: reconnecting")
+	}
+}
+
+func (r *Recorder) connect() (*zb.WebSocketClient, chan struct{}, error) {
+	r.mu.Lock()
+	for _, symbol := range r.config.Symbols {
+		r.pending[symbol] = []zb.Trade{}
+	}
+	r.mu.Unlock()
+
+	ws := r.dial()
+	closed := make(chan struct{})
+	ws.OnClose(func(err error) {
+		close(closed)
+	})
+	if err := ws.TryConnect(); err != nil {
+		return nil, nil, err
+	}
+
+	for _, symbol := range r.config.Symbols {
+		symbol := symbol
+		ws.SubscribeQuote(symbol, func(quote zb.Quote) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.write(quoteStream, symbol, quoteRow(symbol, r.received(), quote))
+		})
+		ws.SubscribeDepth(symbol, func(depth zb.Depth) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			for _, row := range depthRows(symbol, r.received(), depth) {
+				r.write(depthStream, symbol, row)
+			}
+		})
+		ws.SubscribeTrades(symbol, func(trades []zb.Trade) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if pending, ok := r.pending[symbol]; ok {
+				r.pending[symbol] = append(pending, trades...)
+				return
+			}
+			r.writeTrades(symbol, trades)
+		})
+	}
+	return ws, closed, nil
+}
+
+// record polls until the connection closes, ctx is done or writing fails.
+func (r *Recorder) record(ctx context.Context, closed chan struct{}) error {
+	ticker := time.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		r.poll(ctx)
+		select {
+		case err := <-r.failed:
+			return err
+		case <-ctx.Done():
+			return nil
+		case <-closed:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Recorder) poll(ctx context.Context) {
+	for _, symbol := range r.config.Symbols {
+		if err := r.fillTrades(ctx, symbol); err != nil && ctx.Err() == nil {
+			log.Println("recorder: fill trades of " + symbol + ": " + err.Error())
+		}
+		if err := r.pollKlines(ctx, symbol); err != nil && ctx.Err() == nil {
+			log.Println("recorder: poll klines of " + symbol + ": " + err.Error())
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	for _, p := range r.partitions {
+		if err := p.expire(now); err != nil {
+			r.fail(err)
+		}
+	}
+	if err := r.saveCursors(); err != nil {
+		r.fail(err)
+	}
+}
+
+// fillTrades pages the REST trades since the last recorded one, then releases the
+// websocket trades held back meanwhile.
+func (r *Recorder) fillTrades(ctx context.Context, symbol string) error {
+	r.mu.Lock()
+	_, filling := r.pending[symbol]
+	since := r.lastTid[symbol]
+	r.mu.Unlock()
+	if !filling {
+		return nil
+	}
+
+	var fetched []zb.Trade
+	for {
+		trades, err := r.market.GetTrades(symbol, since)
+		if err != nil {
+			return err
+		}
+		fetched = append(fetched, trades...)
+
+		last := since
+		for _, t := range trades {
+			if t.Id > last {
+				last = t.Id
+			}
+		}
+		if len(trades) < fullTradePage || last == since {
+			break
+		}
+		since = last
+		if !sleep(ctx, tradePageInterval) {
+			return ctx.Err()
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writeTrades(symbol, append(fetched, r.pending[symbol]...))
+	delete(r.pending, symbol)
+	return nil
+}
+
+func (r *Recorder) writeTrades(symbol string, trades []zb.Trade) {
+	seen := map[uint64]bool{}
+	var batch []zb.Trade
+	for _, t := range trades {
+		if t.Id > r.lastTid[symbol] && !seen[t.Id] {
+			seen[t.Id] = true
+			batch = append(batch, t)
+		}
+	}
+	if len(batch) == 0 {
+		return
+	}
+	sort.Slice(batch, func(i, j int) bool { return batch[i].Id < batch[j].Id })
+
+	received := r.received()
+	for _, t := range batch {
+		r.write(tradeStream, symbol, tradeRow(symbol, received, t))
+	}
+	r.lastTid[symbol] = batch[len(batch)-1].Id
+	r.save(tradeStream, symbol, r.tradeCursor, r.lastTid[symbol])
+}
+
+// pollKlines records the klines closed since the last recorded one. A fresh recorder
+// starts initialKlines periods back.
+func (r *Recorder) pollKlines(ctx context.Context, symbol string) error {
+	step := uint64(r.config.Period.Duration() / time.Millisecond)
+	now := uint64(r.received())
+	to := now - now%step
+
+	r.mu.Lock()
+	from := r.lastKline[symbol] + step
+	r.mu.Unlock()
+	if from == step {
+		from = to - initialKlines*step
+	}
+	if from >= to {
+		return nil
+	}
+
+	klines, _, err := zb.BackfillKlines(ctx, r.market, symbol, r.config.Period, from, to)
+	if err != nil || len(klines) == 0 {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	received := r.received()
+	for _, k := range klines {
+		r.write(klineStream, symbol, klineRow(symbol, received, r.config.Period, k))
+	}
+	r.lastKline[symbol] = klines[len(klines)-1].Time
+	r.save(klineStream, symbol, r.klineCursor, r.lastKline[symbol])
+	return nil
+}
+
+// write hands row to the partition of every format. r.mu must be held.
+func (r *Recorder) write(s stream, symbol string, row row) {
+	now := r.now()
+	for _, format := range r.config.Formats {
+		key := s.name + "/" + symbol + "/" + format.String()
+		p, ok := r.partitions[key]
+		if !ok {
+			p = &partition{dir: r.config.Dir, stream: s, symbol: symbol, format: format, rotate: r.config.Rotate}
+			r.partitions[key] = p
+		}
+		if err := p.write(now, row); err != nil {
+			r.fail(err)
+		}
+	}
+}
+
+// save flushes the partitions of s, which hold the rows up to value, and moves the
+// cursor past the rows they hold durably. r.mu must be held.
+func (r *Recorder) save(s stream, symbol string, cursor zb.TradeCursor, value uint64) {
+	for _, format := range r.config.Formats {
+		if p, ok := r.partitions[s.name+"/"+symbol+"/"+format.String()]; ok {
+			if err := p.flush(value); err != nil {
+				r.fail(err)
+				return
+			}
+		}
+	}
+	if err := r.saveCursor(s, symbol, cursor); err != nil {
+		r.fail(err)
+	}
+}
+
+// saveCursor saves the last value every format of s holds durably, if it moved. r.mu
+// must be held.
+func (r *Recorder) saveCursor(s stream, symbol string, cursor zb.TradeCursor) error {
+	value := uint64(math.MaxUint64)
+	for _, format := range r.config.Formats {
+		p, ok := r.partitions[s.name+"/"+symbol+"/"+format.String()]
+		if !ok {
+			return nil
+		}
+		if p.durable < value {
+			value = p.durable
+		}
+	}
+
+	key := s.name + "/" + symbol
+	if value <= r.saved[key] {
+		return nil
+	}
+	if err := cursor.Save(symbol, value); err != nil {
+		return err
+	}
+	r.saved[key] = value
+	return nil
+}
+
+// saveCursors saves the trade and kline cursors of every symbol, for when partitions
+// were closed. r.mu must be held.
+func (r *Recorder) saveCursors() error {
+	for _, symbol := range r.config.Symbols {
+		if err := r.saveCursor(tradeStream, symbol, r.tradeCursor); err != nil {
+			return err
+		}
+		if err := r.saveCursor(klineStream, symbol, r.klineCursor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Recorder) fail(err error) {
+	select {
+	case r.failed <- err:
+	default:
+	}
+}
+
+func (r *Recorder) received() int64 {
+	return r.now().UnixNano() / int64(time.Millisecond)
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
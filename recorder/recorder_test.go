@@ -0,0 +1,196 @@
+package recorder
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"github.com/berryland/zb"
+	"github.com/berryland/zb/zbtest"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestRecorder_Run(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "recorder")
+	defer os.RemoveAll(dir)
+
+	s := zbtest.NewServer()
+	defer s.Close()
+	minute := uint64(time.Minute / time.Millisecond)
+	now := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	open := now - now%minute
+	s.SetKlines("btc_usdt", []zb.Kline{{Time: open - 2*minute, Close: 1}, {Time: open - minute, Close: 2}, {Time: open, Close: 3}})
+	s.SetTrades("btc_usdt", []zb.Trade{{Id: 1, Price: 10}, {Id: 2, Price: 11}})
+
+	config := Config{Dir: dir, Symbols: []string{"btc_usdt"}, Formats: []Format{CSV, Parquet}, PollInterval: 20 * time.Millisecond, ReconnectDelay: 20 * time.Millisecond}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- New(config, s.RestClient(), s.WebSocketClient).Run(ctx)
+	}()
+
+	channel := zbtest.Channel("btc_usdt", "trades")
+	assert.True(t, s.WaitSubscribed(channel, time.Second))
+	waitFor(t, func() bool { return len(rows(dir, "trades", ".csv.part")) == 2 })
+	s.PublishTrades("btc_usdt", []zb.Trade{{Id: 3, Price: 12}, {Id: 2, Price: 11}})
+	s.PublishDepth("btc_usdt", zb.Depth{Asks: []zb.DepthEntry{{Price: 12, Volume: 1}}, Bids: []zb.DepthEntry{{Price: 11, Volume: 2}}, Time: 1516029960})
+	waitFor(t, func() bool { return len(rows(dir, "trades", ".csv.part")) == 3 })
+
+	// trades 4 and 5 happen while disconnected and have to come from the REST API
+	s.DropConnections()
+	s.SetTrades("btc_usdt", []zb.Trade{{Id: 1}, {Id: 2}, {Id: 3}, {Id: 4, Price: 13}, {Id: 5, Price: 14}})
+	assert.True(t, s.WaitSubscribed(channel, time.Second))
+	waitFor(t, func() bool { return len(rows(dir, "trades", ".csv.part")) == 5 })
+
+	cancel()
+	assert.Nil(t, <-done)
+
+	trades := rows(dir, "trades", ".csv")
+	for i, row := range trades {
+		assert.Equal(t, "btc_usdt", row[0])
+		assert.Equal(t, formatInt(int64(i+1)), row[2])
+	}
+	klines := rows(dir, "klines", ".csv")
+	if assert.Len(t, klines, 2) {
+		assert.Equal(t, "1min", klines[0][2])
+		assert.Equal(t, "1", klines[0][7])
+		assert.Equal(t, "2", klines[1][7])
+	}
+	assert.Len(t, rows(dir, "depth", ".csv"), 2)
+	assert.NotEmpty(t, rows(dir, "quotes", ".csv"))
+
+	parquets, _ := filepath.Glob(filepath.Join(dir, "*", "v1", "date=*", "btc_usdt-*.parquet"))
+	assert.Len(t, parquets, 4)
+	for _, path := range parquets {
+		content, _ := ioutil.ReadFile(path)
+		assert.True(t, bytes.HasPrefix(content, []byte("PAR1")) && bytes.HasSuffix(content, []byte("PAR1")), path)
+	}
+
+	// a restarted recorder resumes from the cursors instead of recording trades again
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		done <- New(config, s.RestClient(), s.WebSocketClient).Run(ctx)
+	}()
+	assert.True(t, s.WaitSubscribed(channel, time.Second))
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	assert.Nil(t, <-done)
+	assert.Len(t, rows(dir, "trades", ".csv"), 5)
+	assert.Len(t, rows(dir, "klines", ".csv"), 2)
+}
+
+func TestRecorder_Crash(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "recorder")
+	defer os.RemoveAll(dir)
+
+	config := Config{Dir: dir, Symbols: []string{"btc_usdt"}, Formats: []Format{CSV, Parquet}}
+	r := New(config, nil, nil)
+	r.write(tradeStream, "btc_usdt", TradeRow{Id: 1})
+	r.save(tradeStream, "btc_usdt", r.tradeCursor, 1)
+	// the Parquet row cannot be read back before its file is closed
+	tid, _ := r.tradeCursor.Load("btc_usdt")
+	assert.Equal(t, uint64(0), tid)
+
+	// the recorder crashes halfway through a line
+	parts, _ := filepath.Glob(filepath.Join(dir, "trades", "v1", "date=*", "*.csv.part"))
+	if assert.Len(t, parts, 1) {
+		f, _ := os.OpenFile(parts[0], os.O_APPEND|os.O_WRONLY, 0644)
+		f.WriteString("btc_usdt,15")
+		f.Close()
+	}
+	assert.Nil(t, recoverParts(dir))
+	trades := rows(dir, "trades", ".csv")
+	if assert.Len(t, trades, 1) {
+		assert.Equal(t, "1", trades[0][2])
+	}
+	orphans, _ := filepath.Glob(filepath.Join(dir, "trades", "v1", "date=*", "*.parquet"+orphanSuffix))
+	assert.Len(t, orphans, 1)
+	parts, _ = filepath.Glob(filepath.Join(dir, "*", "v1", "date=*", "*"+partSuffix))
+	assert.Empty(t, parts)
+
+	// the cursor moves once the Parquet file is closed
+	r = New(config, nil, nil)
+	r.write(tradeStream, "btc_usdt", TradeRow{Id: 1})
+	r.save(tradeStream, "btc_usdt", r.tradeCursor, 1)
+	for _, p := range r.partitions {
+		assert.Nil(t, p.close())
+	}
+	assert.Nil(t, r.saveCursors())
+	tid, _ = r.tradeCursor.Load("btc_usdt")
+	assert.Equal(t, uint64(1), tid)
+
+	// flushed CSV rows are durable right away
+	config.Formats = []Format{CSV}
+	r = New(config, nil, nil)
+	r.write(tradeStream, "btc_usdt", TradeRow{Id: 2})
+	r.save(tradeStream, "btc_usdt", r.tradeCursor, 2)
+	tid, _ = r.tradeCursor.Load("btc_usdt")
+	assert.Equal(t, uint64(2), tid)
+}
+
+func TestPartition_Rotate(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "recorder")
+	defer os.RemoveAll(dir)
+
+	p := &partition{dir: dir, stream: tradeStream, symbol: "btc_usdt", format: CSV, rotate: 12 * time.Hour}
+	at := func(s string) time.Time {
+		t, _ := time.Parse(time.RFC3339, s)
+		return t
+	}
+	assert.Nil(t, p.write(at("2018-01-22T10:59:59Z"), TradeRow{Id: 1}))
+	assert.Nil(t, p.write(at("2018-01-22T11:00:00Z"), TradeRow{Id: 2}))
+	assert.Nil(t, p.write(at("2018-01-22T12:00:00Z"), TradeRow{Id: 3}))
+	assert.Nil(t, p.expire(at("2018-01-23T00:00:00Z")))
+	assert.Nil(t, p.write(at("2018-01-23T01:00:00Z"), TradeRow{Id: 4}))
+	assert.Nil(t, p.close())
+
+	// a second recorder in the same interval does not overwrite the first one's file
+	p = &partition{dir: dir, stream: tradeStream, symbol: "btc_usdt", format: CSV, rotate: 12 * time.Hour}
+	assert.Nil(t, p.write(at("2018-01-23T02:00:00Z"), TradeRow{Id: 5}))
+	assert.Nil(t, p.close())
+
+	paths, _ := filepath.Glob(filepath.Join(dir, "trades", "v1", "date=*", "*"))
+	for i := range paths {
+		paths[i], _ = filepath.Rel(dir, paths[i])
+	}
+	assert.Equal(t, []string{
+		filepath.Join("trades", "v1", "date=2018-01-22", "btc_usdt-000000.csv"),
+		filepath.Join("trades", "v1", "date=2018-01-22", "btc_usdt-120000.csv"),
+		filepath.Join("trades", "v1", "date=2018-01-23", "btc_usdt-000000-1.csv"),
+		filepath.Join("trades", "v1", "date=2018-01-23", "btc_usdt-000000.csv"),
+	}, paths)
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("Condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// rows reads every file of a stream ending in ext, in name order and without headers.
+func rows(dir string, stream string, ext string) [][]string {
+	paths, _ := filepath.Glob(filepath.Join(dir, stream, "v1", "date=*", "*"+ext))
+	sort.Strings(paths)
+	var all [][]string
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		records, _ := csv.NewReader(f).ReadAll()
+		f.Close()
+		if len(records) > 0 {
+			all = append(all, records[1:]...)
+		}
+	}
+	return all
+}
@@ -0,0 +1,125 @@
+package recorder
+
+import (
+	"github.com/berryland/zb"
+	"strconv"
+)
+
+// SchemaVersion is part of every file path. It is bumped whenever a column is added,
+// removed or changes meaning, so files of different layouts never share a directory.
+const SchemaVersion = 1
+
+// Received is the local time in milliseconds a row was recorded at, Time is the time
+// zb reports for the event.
+type QuoteRow struct {
+	Symbol   string  `parquet:"name=symbol, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Received int64   `parquet:"name=received, type=INT64"`
+	Time     int64   `parquet:"name=time, type=INT64"`
+	Last     float64 `parquet:"name=last, type=DOUBLE"`
+	Buy      float64 `parquet:"name=buy, type=DOUBLE"`
+	Sell     float64 `parquet:"name=sell, type=DOUBLE"`
+	High     float64 `parquet:"name=high, type=DOUBLE"`
+	Low      float64 `parquet:"name=low, type=DOUBLE"`
+	Volume   float64 `parquet:"name=volume, type=DOUBLE"`
+}
+
+// DepthRow is one price level of a depth snapshot, Level 0 being the best price of Side.
+type DepthRow struct {
+	Symbol   string  `parquet:"name=symbol, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Received int64   `parquet:"name=received, type=INT64"`
+	Time     int64   `parquet:"name=time, type=INT64"`
+	Side     string  `parquet:"name=side, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Level    int32   `parquet:"name=level, type=INT32"`
+	Price    float64 `parquet:"name=price, type=DOUBLE"`
+	Volume   float64 `parquet:"name=volume, type=DOUBLE"`
+}
+
+type TradeRow struct {
+	Symbol   string  `parquet:"name=symbol, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Received int64   `parquet:"name=received, type=INT64"`
+	Id       int64   `parquet:"name=id, type=INT64"`
+	Time     int64   `parquet:"name=time, type=INT64"`
+	Side     string  `parquet:"name=side, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Price    float64 `parquet:"name=price, type=DOUBLE"`
+	Amount   float64 `parquet:"name=amount, type=DOUBLE"`
+}
+
+type KlineRow struct {
+	Symbol   string  `parquet:"name=symbol, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Received int64   `parquet:"name=received, type=INT64"`
+	Period   string  `parquet:"name=period, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Time     int64   `parquet:"name=time, type=INT64"`
+	Open     float64 `parquet:"name=open, type=DOUBLE"`
+	High     float64 `parquet:"name=high, type=DOUBLE"`
+	Low      float64 `parquet:"name=low, type=DOUBLE"`
+	Close    float64 `parquet:"name=close, type=DOUBLE"`
+	Volume   float64 `parquet:"name=volume, type=DOUBLE"`
+}
+
+type row interface {
+	values() []string
+}
+
+func (r QuoteRow) values() []string {
+	return []string{r.Symbol, formatInt(r.Received), formatInt(r.Time), formatFloat(r.Last), formatFloat(r.Buy), formatFloat(r.Sell), formatFloat(r.High), formatFloat(r.Low), formatFloat(r.Volume)}
+}
+
+func (r DepthRow) values() []string {
+	return []string{r.Symbol, formatInt(r.Received), formatInt(r.Time), r.Side, formatInt(int64(r.Level)), formatFloat(r.Price), formatFloat(r.Volume)}
+}
+
+func (r TradeRow) values() []string {
+	return []string{r.Symbol, formatInt(r.Received), formatInt(r.Id), formatInt(r.Time), r.Side, formatFloat(r.Price), formatFloat(r.Amount)}
+}
+
+func (r KlineRow) values() []string {
+	return []string{r.Symbol, formatInt(r.Received), r.Period, formatInt(r.Time), formatFloat(r.Open), formatFloat(r.High), formatFloat(r.Low), formatFloat(r.Close), formatFloat(r.Volume)}
+}
+
+type stream struct {
+	name      string
+	header    []string
+	prototype interface{}
+}
+
+var (
+	quoteStream = stream{"quotes", []string{"symbol", "received", "time", "last", "buy", "sell", "high", "low", "volume"}, new(QuoteRow)}
+	depthStream = stream{"depth", []string{"symbol", "received", "time", "side", "level", "price", "volume"}, new(DepthRow)}
+	tradeStream = stream{"trades", []string{"symbol", "received", "id", "time", "side", "price", "amount"}, new(TradeRow)}
+	klineStream = stream{"klines", []string{"symbol", "received", "period", "time", "open", "high", "low", "close", "volume"}, new(KlineRow)}
+)
+
+func quoteRow(symbol string, received int64, q zb.Quote) QuoteRow {
+	return QuoteRow{Symbol: symbol, Received: received, Time: int64(q.Time), Last: q.Last, Buy: q.Buy, Sell: q.Sell, High: q.High, Low: q.Low, Volume: q.Volume}
+}
+
+func depthRows(symbol string, received int64, d zb.Depth) []DepthRow {
+	var rows []DepthRow
+	for i, e := range d.Asks {
+		rows = append(rows, DepthRow{Symbol: symbol, Received: received, Time: int64(d.Time), Side: "ask", Level: int32(i), Price: e.Price, Volume: e.Volume})
+	}
+	for i, e := range d.Bids {
+		rows = append(rows, DepthRow{Symbol: symbol, Received: received, Time: int64(d.Time), Side: "bid", Level: int32(i), Price: e.Price, Volume: e.Volume})
+	}
+	return rows
+}
+
+func tradeRow(symbol string, received int64, t zb.Trade) TradeRow {
+	side := "buy"
+	if t.TradeType == zb.Sell {
+		side = "sell"
+	}
+	return TradeRow{Symbol: symbol, Received: received, Id: int64(t.Id), Time: int64(t.Time), Side: side, Price: t.Price, Amount: t.Amount}
+}
+
+func klineRow(symbol string, received int64, period zb.Period, k zb.Kline) KlineRow {
+	return KlineRow{Symbol: symbol, Received: received, Period: period.String(), Time: int64(k.Time), Open: k.Open, High: k.High, Low: k.Low, Close: k.Close, Volume: k.Volume}
+}
+
+func formatInt(i int64) string {
+	return strconv.FormatInt(i, 10)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
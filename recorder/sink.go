@@ -0,0 +1,285 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Format uint8
+
+const (
+	CSV Format = iota
+	Parquet
+)
+
+var formats = []string{"csv", "parquet"}
+
+func ParseFormat(string string) (Format, error) {
+	for i, name := range formats {
+		if name == string {
+			return Format(i), nil
+		}
+	}
+	return 0, errors.New("unknown format: " + string)
+}
+
+func (f Format) String() string {
+	return formats[f]
+}
+
+// partSuffix marks files still being written. Parquet files are only readable once
+// closed, so readers should skip them. orphanSuffix marks Parquet files a crash left
+// without footer; their rows are recorded again after the restart.
+const (
+	partSuffix   = ".part"
+	orphanSuffix = ".orphan"
+)
+
+type file interface {
+	write(r row) error
+	// flush reports whether the rows written so far would survive a crash
+	flush() (bool, error)
+	close() error
+}
+
+type csvFile struct {
+	file   *os.File
+	writer *csv.Writer
+}
+
+func newCsvFile(path string, s stream) (*csvFile, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := csv.NewWriter(f)
+	if err := w.Write(s.header); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &csvFile{file: f, writer: w}, nil
+}
+
+func (f *csvFile) write(r row) error {
+	return f.writer.Write(r.values())
+}
+
+func (f *csvFile) flush() (bool, error) {
+	f.writer.Flush()
+	if err := f.writer.Error(); err != nil {
+		return false, err
+	}
+	return true, f.file.Sync()
+}
+
+func (f *csvFile) close() error {
+	_, err := f.flush()
+	if cerr := f.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+type parquetFile struct {
+	file   *os.File
+	writer *writer.ParquetWriter
+}
+
+func newParquetFile(path string, s stream) (*parquetFile, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := writer.NewParquetWriterFromWriter(f, s.prototype, 1)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	version := strconv.Itoa(SchemaVersion)
+	w.Footer.KeyValueMetadata = append(w.Footer.KeyValueMetadata, &parquet.KeyValue{Key: "zb.schema_version", Value: &version})
+	return &parquetFile{file: f, writer: w}, nil
+}
+
+func (f *parquetFile) write(r row) error {
+	return f.writer.Write(r)
+}
+
+// flush does nothing: without the footer written on close, none of the rows of a
+// Parquet file can be read back.
+func (f *parquetFile) flush() (bool, error) {
+	return false, nil
+}
+
+func (f *parquetFile) close() error {
+	err := f.writer.WriteStop()
+	if cerr := f.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// partition writes one stream of one symbol in one format. It opens a new file for
+// every rotate interval, aligned to UTC, and never lets a file span midnight:
+//
+//	<dir>/<stream>/v<SchemaVersion>/date=2018-01-22/btc_usdt-150000.csv
+type partition struct {
+	dir    string
+	stream stream
+	symbol string
+	format Format
+	rotate time.Duration
+
+	file  file
+	path  string
+	until time.Time
+	// marked is the cursor value of the last row written, durable that of the last row
+	// that survives a crash
+	marked  uint64
+	durable uint64
+}
+
+func (p *partition) write(now time.Time, r row) error {
+	if p.file != nil && !now.Before(p.until) {
+		if err := p.close(); err != nil {
+			return err
+		}
+	}
+	if p.file == nil {
+		if err := p.open(now); err != nil {
+			return err
+		}
+	}
+	return p.file.write(r)
+}
+
+func (p *partition) open(now time.Time) error {
+	start := now.UTC().Truncate(p.rotate)
+	day := now.UTC().Truncate(24 * time.Hour)
+	p.until = start.Add(p.rotate)
+	if end := day.Add(24 * time.Hour); p.until.After(end) {
+		p.until = end
+	}
+
+	dir := filepath.Join(p.dir, p.stream.name, "v"+strconv.Itoa(SchemaVersion), "date="+day.Format("2006-01-02"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	path, err := unusedPath(filepath.Join(dir, p.symbol+"-"+start.Format("150405")), "."+p.format.String())
+	if err != nil {
+		return err
+	}
+
+	var f file
+	if p.format == Parquet {
+		f, err = newParquetFile(path+partSuffix, p.stream)
+	} else {
+		f, err = newCsvFile(path+partSuffix, p.stream)
+	}
+	if err != nil {
+		return err
+	}
+	p.file, p.path = f, path
+	return nil
+}
+
+// unusedPath adds a counter to base when a restart finds the file of the current
+// interval already there.
+func unusedPath(base string, ext string) (string, error) {
+	for i := 0; ; i++ {
+		path := base + ext
+		if i > 0 {
+			path = base + "-" + strconv.Itoa(i) + ext
+		}
+		_, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			_, err = os.Stat(path + partSuffix)
+		}
+		if os.IsNotExist(err) {
+			return path, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// flush makes the rows written so far durable where the format allows it, and records
+// value as the cursor they reach.
+func (p *partition) flush(value uint64) error {
+	p.marked = value
+	if p.file == nil {
+		p.durable = value
+		return nil
+	}
+	durable, err := p.file.flush()
+	if err == nil && durable {
+		p.durable = value
+	}
+	return err
+}
+
+// expire closes the file once its interval is over, even if nothing new arrived.
+func (p *partition) expire(now time.Time) error {
+	if p.file == nil || now.Before(p.until) {
+		return nil
+	}
+	return p.close()
+}
+
+func (p *partition) close() error {
+	if p.file == nil {
+		return nil
+	}
+	err := p.file.close()
+	p.file = nil
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(p.path+partSuffix, p.path); err != nil {
+		return err
+	}
+	p.durable = p.marked
+	return nil
+}
+
+// recoverParts finishes the files a crashed recorder left under dir. CSV files lose a
+// torn last line and get their final name, Parquet files cannot be read without their
+// footer and are set aside with orphanSuffix.
+func recoverParts(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, partSuffix) {
+			return err
+		}
+
+		final := strings.TrimSuffix(path, partSuffix)
+		if strings.HasSuffix(final, "."+Parquet.String()) {
+			return os.Rename(path, final+orphanSuffix)
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := os.Truncate(path, int64(bytes.LastIndexByte(content, '\n')+1)); err != nil {
+			return err
+		}
+		if _, err := os.Stat(final); !os.IsNotExist(err) {
+			ext := filepath.Ext(final)
+			if final, err = unusedPath(strings.TrimSuffix(final, ext), ext); err != nil {
+				return err
+			}
+		}
+		return os.Rename(path, final)
+	})
+}
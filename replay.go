@@ -0,0 +1,138 @@
+package zb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	AsFastAsPossible = float64(0)
+	RealTime         = float64(1)
+)
+
+// Frame is a raw websocket message together with the unix time in milliseconds it was received at
+type Frame struct {
+	Time  uint64
+	Bytes []byte
+}
+
+// FrameWriter writes frames as lines of "<time>\t<compacted json>"
+type FrameWriter struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+func NewFrameWriter(w io.Writer) *FrameWriter {
+	return &FrameWriter{writer: w}
+}
+
+func (w *FrameWriter) Write(frame Frame) error {
+	var buf bytes.Buffer
+	buf.WriteString(strconv.FormatUint(frame.Time, 10))
+	buf.WriteByte('\t')
+	if err := json.Compact(&buf, frame.Bytes); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.writer.Write(buf.Bytes())
+	return err
+}
+
+type FrameReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func NewFrameReader(r io.Reader) *FrameReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return &FrameReader{scanner: scanner}
+}
+
+// Read returns io.EOF after the last frame
+func (r *FrameReader) Read() (Frame, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := r.scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		i := bytes.IndexByte(line, '\t')
+		if i < 0 {
+			return Frame{}, fmt.Errorf("Malformed frame at line %d", r.line)
+		}
+		t, err := strconv.ParseUint(string(line[:i]), 10, 64)
+		if err != nil {
+			return Frame{}, fmt.Errorf("Malformed frame time at line %d: %v", r.line, err)
+		}
+		frame := make([]byte, len(line)-i-1)
+		copy(frame, line[i+1:])
+		return Frame{Time: t, Bytes: frame}, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return Frame{}, err
+	}
+	return Frame{}, io.EOF
+}
+
+// Replayer feeds recorded frames to the callbacks registered on a WebSocketClient.
+// A speed of RealTime keeps the recorded gaps between frames, 2 replays twice as fast
+// and AsFastAsPossible does not wait at all.
+type Replayer struct {
+	reader *FrameReader
+	speed  float64
+}
+
+func NewReplayer(r io.Reader, speed float64) *Replayer {
+	return &Replayer{reader: NewFrameReader(r), speed: speed}
+}
+
+func (r *Replayer) Replay(ctx context.Context, c *WebSocketClient) error {
+	if r.speed < 0 {
+		return errors.New("Replay speed must not be negative")
+	}
+
+	var last uint64
+	for {
+		frame, err := r.reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if r.speed > 0 && last > 0 && frame.Time > last {
+			wait := time.Duration(float64(frame.Time-last) * float64(time.Millisecond) / r.speed)
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+		last = frame.Time
+
+		c.dispatch(frame.Bytes)
+	}
+}
+
+func nowMillis() uint64 {
+	return uint64(time.Now().UnixNano() / int64(time.Millisecond))
+}
@@ -0,0 +1,61 @@
+package zb
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+const quoteFrame = `{"date":"1516029900000","ticker":{"vol":"100.5","last":"11000.1","sell":"11001","buy":"10999","high":"12000","low":"10000"},"channel":"btcusdt_ticker"}`
+
+func recordFrames(times ...uint64) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	w := NewFrameWriter(buf)
+	for _, t := range times {
+		w.Write(Frame{Time: t, Bytes: []byte(quoteFrame)})
+	}
+	return buf
+}
+
+func TestReplayer_Replay(t *testing.T) {
+	c := NewWebSocketClient()
+	var quotes []Quote
+	c.SubscribeQuote("btc_usdt", func(quote Quote) {
+		quotes = append(quotes, quote)
+	})
+
+	err := NewReplayer(recordFrames(1000, 2000, 3000), AsFastAsPossible).Replay(context.Background(), c)
+	assert.Nil(t, err)
+	assert.Len(t, quotes, 3)
+	assert.Equal(t, 11000.1, quotes[0].Last)
+	assert.Equal(t, uint64(1516029900000), quotes[0].Time)
+}
+
+func TestReplayer_ReplayAccelerated(t *testing.T) {
+	c := NewWebSocketClient()
+	count := 0
+	c.SubscribeQuote("btc_usdt", func(quote Quote) {
+		count++
+	})
+
+	start := time.Now()
+	err := NewReplayer(recordFrames(1000, 1100, 1200), 2).Replay(context.Background(), c)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, count)
+	assert.True(t, time.Since(start) >= 100*time.Millisecond)
+}
+
+func TestReplayer_ReplayCancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := NewReplayer(recordFrames(1000, 60000), RealTime).Replay(ctx, NewWebSocketClient())
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestFrameReader_Read(t *testing.T) {
+	_, err := NewFrameReader(bytes.NewBufferString("not a frame\n")).Read()
+	assert.NotNil(t, err)
+}
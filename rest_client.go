@@ -13,6 +13,8 @@ import (
 	"strings"
 	"net/http"
 	"io/ioutil"
+	stdjson "encoding/json"
+	"context"
 )
 
 const (
@@ -21,18 +23,31 @@ const (
 )
 
 type RestClient struct {
-	client *http.Client
+	client      *http.Client
+	dataApiUrl  string
+	tradeApiUrl string
 }
 
 func NewRestClient() *RestClient {
 	c := new(RestClient)
 	c.client = &http.Client{}
+	c.dataApiUrl = DataApiUrl
+	c.tradeApiUrl = TradeApiUrl
 	return c
 }
 
+func (c *RestClient) SetHttpClient(client *http.Client) {
+	c.client = client
+}
+
+func (c *RestClient) SetBaseUrls(dataApiUrl string, tradeApiUrl string) {
+	c.dataApiUrl = dataApiUrl
+	c.tradeApiUrl = tradeApiUrl
+}
+
 func (c *RestClient) GetSymbols() (map[string]SymbolConfig, error) {
 	configs := map[string]SymbolConfig{}
-	resp, err := c.doGet(DataApiUrl + "markets")
+	resp, err := c.doGet(c.dataApiUrl + "markets")
 	if err != nil {
 		return configs, err
 	}
@@ -57,7 +72,7 @@ func (c *RestClient) GetLatestQuote(symbol string) (Quote, error) {
 	q := map[string]string{
 		"market": symbol,
 	}
-	resp, err := c.doGet(buildUrl(DataApiUrl+"ticker", q).String())
+	resp, err := c.doGet(buildUrl(c.dataApiUrl+"ticker", q).String())
 	if err != nil {
 		return Quote{}, err
 	}
@@ -71,15 +86,56 @@ func (c *RestClient) GetLatestQuote(symbol string) (Quote, error) {
 	return marshalQuote(bytes), nil
 }
 
+// GetAllQuotes returns the quotes of every market keyed by symbol, e.g. btc_usdt. zb
+// names the markets without underscore there, so the symbols of GetSymbols are fetched
+// to translate them. zb does not date the quotes, so Time is when they were fetched.
+func (c *RestClient) GetAllQuotes() (map[string]Quote, error) {
+	quotes := map[string]Quote{}
+	symbols, err := c.GetSymbols()
+	if err != nil {
+		return quotes, err
+	}
+	names := make(map[string]string, len(symbols))
+	for symbol := range symbols {
+		names[strings.Replace(symbol, "_", "", 1)] = symbol
+	}
+
+	resp, err := c.doGet(c.dataApiUrl + "allTicker")
+	if err != nil {
+		return quotes, err
+	}
+
+	bytes := resp.ReadBytes()
+	err = extractDataError(bytes)
+	if err != nil {
+		return quotes, err
+	}
+
+	now := nowMillis()
+	json.ObjectEach(bytes, func(key []byte, value []byte, dataType json.ValueType, offset int) error {
+		market, _ := json.ParseString(key)
+		// markets missing from GetSymbols keep zb's name
+		if symbol, ok := names[market]; ok {
+			market = symbol
+		}
+		quotes[market] = marshalTicker(value, now)
+		return nil
+	})
+	return quotes, nil
+}
+
 func (c *RestClient) GetKlines(symbol string, period string, since uint64, size uint16) ([]Kline, error) {
 	var klines []Kline
+	if _, err := ParsePeriod(period); err != nil {
+		return klines, err
+	}
 	q := map[string]string{
 		"market": symbol,
 		"type":   period,
 		"since":  strconv.FormatUint(since, 10),
 		"size":   strconv.FormatUint(uint64(size), 10),
 	}
-	resp, err := c.doGet(buildUrl(DataApiUrl+"kline", q).String())
+	resp, err := c.doGet(buildUrl(c.dataApiUrl+"kline", q).String())
 	if err != nil {
 		return klines, err
 	}
@@ -109,7 +165,7 @@ func (c *RestClient) GetTrades(symbol string, since uint64) ([]Trade, error) {
 		"market": symbol,
 		"since":  strconv.FormatUint(since, 10),
 	}
-	resp, err := c.doGet(buildUrl(DataApiUrl+"trades", q).String())
+	resp, err := c.doGet(buildUrl(c.dataApiUrl+"trades", q).String())
 	if err != nil {
 		return trades, err
 	}
@@ -120,20 +176,7 @@ func (c *RestClient) GetTrades(symbol string, since uint64) ([]Trade, error) {
 		return trades, err
 	}
 
-	json.ArrayEach(bytes, func(value []byte, dataType json.ValueType, offset int, err error) {
-		id, _ := json.GetInt(value, "tid")
-		tradeType, _ := json.GetString(value, "type")
-		amountString, _ := json.GetString(value, "amount")
-		priceString, _ := json.GetString(value, "price")
-		time, _ := json.GetInt(value, "date")
-
-		amount, _ := strconv.ParseFloat(amountString, 64)
-		price, _ := strconv.ParseFloat(priceString, 64)
-
-		trades = append(trades, Trade{Id: uint64(id), TradeType: ParseTradeType(tradeType), Price: price, Amount: amount, Time: uint64(time)})
-	})
-
-	return trades, nil
+	return marshalTrades(bytes), nil
 }
 
 func (c *RestClient) GetDepth(symbol string, size uint8) (Depth, error) {
@@ -141,7 +184,7 @@ func (c *RestClient) GetDepth(symbol string, size uint8) (Depth, error) {
 		"market": symbol,
 		"size":   strconv.FormatUint(uint64(size), 10),
 	}
-	resp, err := c.doGet(buildUrl(DataApiUrl+"depth", q).String())
+	resp, err := c.doGet(buildUrl(c.dataApiUrl+"depth", q).String())
 	if err != nil {
 		return Depth{}, err
 	}
@@ -152,10 +195,7 @@ func (c *RestClient) GetDepth(symbol string, size uint8) (Depth, error) {
 		return Depth{}, err
 	}
 
-	time, _ := json.GetInt(bytes, "timestamp")
-	asks, bids := marshalDepthEntries(bytes, "asks"), marshalDepthEntries(bytes, "bids")
-
-	return Depth{Asks: asks, Bids: bids, Time: uint64(time)}, nil
+	return marshalDepth(bytes), nil
 }
 
 func (c *RestClient) GetAccount(accessKey string, secretKey string) (Account, error) {
@@ -163,7 +203,7 @@ func (c *RestClient) GetAccount(accessKey string, secretKey string) (Account, er
 		"accesskey": accessKey,
 		"method":    "getAccountInfo",
 	}
-	u := buildUrl(TradeApiUrl+"getAccountInfo", q)
+	u := buildUrl(c.tradeApiUrl+"getAccountInfo", q)
 	sign(u, secretKey)
 
 	resp, err := c.doGet(u.String())
@@ -210,7 +250,7 @@ func (c *RestClient) PlaceOrder(symbol string, price, amount float64, tradeType
 		"accesskey": accessKey,
 		"method":    "order",
 	}
-	u := buildUrl(TradeApiUrl+"order", q)
+	u := buildUrl(c.tradeApiUrl+"order", q)
 	sign(u, secretKey)
 
 	resp, err := c.doGet(u.String())
@@ -236,7 +276,7 @@ func (c *RestClient) CancelOrder(symbol string, id uint64, accessKey, secretKey
 		"accesskey": accessKey,
 		"method":    "cancelOrder",
 	}
-	u := buildUrl(TradeApiUrl+"cancelOrder", q)
+	u := buildUrl(c.tradeApiUrl+"cancelOrder", q)
 	sign(u, secretKey)
 
 	resp, err := c.doGet(u.String())
@@ -253,6 +293,39 @@ func (c *RestClient) CancelOrder(symbol string, id uint64, accessKey, secretKey
 	return nil
 }
 
+// PlaceOrderWithClientId tags the order with clientOrderId, zb's customerOrderId, so it
+// can be found with GetOrderByClientId when the response is lost. zb refuses an id
+// that is already in use.
+func (c *RestClient) PlaceOrderWithClientId(symbol string, price, amount float64, tradeType TradeType, clientOrderId string, accessKey, secretKey string) (uint64, error) {
+	q := map[string]string{
+		"currency":        symbol,
+		"price":           strconv.FormatFloat(price, 'f', -1, 64),
+		"amount":          strconv.FormatFloat(amount, 'f', -1, 64),
+		"tradeType":       strconv.Itoa(int(tradeType)),
+		"customerOrderId": clientOrderId,
+	}
+	bytes, err := c.doTrade("order", q, accessKey, secretKey)
+	if err != nil {
+		return 0, err
+	}
+
+	idString, _ := json.GetString(bytes, "id")
+	id, _ := strconv.ParseUint(idString, 10, 64)
+	return id, nil
+}
+
+func (c *RestClient) GetOrderByClientId(symbol string, clientOrderId string, accessKey, secretKey string) (Order, error) {
+	q := map[string]string{
+		"currency":        symbol,
+		"customerOrderId": clientOrderId,
+	}
+	bytes, err := c.doTrade("getOrder", q, accessKey, secretKey)
+	if err != nil {
+		return Order{}, err
+	}
+	return parseOrder(bytes), nil
+}
+
 func (c *RestClient) GetOrder(symbol string, id uint64, accessKey, secretKey string) (Order, error) {
 	q := map[string]string{
 		"currency":  symbol,
@@ -260,7 +333,7 @@ func (c *RestClient) GetOrder(symbol string, id uint64, accessKey, secretKey str
 		"accesskey": accessKey,
 		"method":    "getOrder",
 	}
-	u := buildUrl(TradeApiUrl+"getOrder", q)
+	u := buildUrl(c.tradeApiUrl+"getOrder", q)
 	sign(u, secretKey)
 
 	resp, err := c.doGet(u.String())
@@ -278,7 +351,7 @@ func (c *RestClient) GetOrder(symbol string, id uint64, accessKey, secretKey str
 }
 
 func (c *RestClient) GetOrders(symbol string, tradeType TradeType, page uint64, size uint16, accessKey, secretKey string) ([]Order, error) {
-	u := getUrlToGetOrders(symbol, tradeType, page, size, accessKey, secretKey)
+	u := c.getUrlToGetOrders(symbol, tradeType, page, size, accessKey, secretKey)
 	resp, err := c.doGet(u.String())
 	if err != nil {
 		return []Order{}, err
@@ -298,6 +371,38 @@ func (c *RestClient) GetOrders(symbol string, tradeType TradeType, page uint64,
 	return orders, nil
 }
 
+// GetOpenOrders returns every pending or partially filled order of symbol.
+func (c *RestClient) GetOpenOrders(symbol string, accessKey, secretKey string) ([]Order, error) {
+	it := newOrderIterator(context.Background(), func(page uint64, size uint16) ([]Order, error) {
+		return c.getUnfinishedOrders(symbol, page, size, accessKey, secretKey)
+	}, OrderFilter{})
+
+	orders := []Order{}
+	for it.Next() {
+		orders = append(orders, it.Order())
+	}
+	return orders, it.Err()
+}
+
+func (c *RestClient) getUnfinishedOrders(symbol string, page uint64, size uint16, accessKey, secretKey string) ([]Order, error) {
+	q := map[string]string{
+		"currency":  symbol,
+		"pageIndex": strconv.FormatUint(page, 10),
+		"pageSize":  strconv.FormatUint(uint64(size), 10),
+	}
+	bytes, err := c.doTrade("getUnfinishedOrdersIgnoreTradeType", q, accessKey, secretKey)
+	if err != nil {
+		return []Order{}, err
+	}
+
+	var orders []Order
+	json.ArrayEach(bytes, func(value []byte, dataType json.ValueType, offset int, err error) {
+		orders = append(orders, parseOrder(value))
+	})
+
+	return orders, nil
+}
+
 func parseOrder(value []byte) Order {
 	idString, _ := json.GetString(value, "id")
 	id, _ := strconv.ParseUint(idString, 10, 64)
@@ -313,18 +418,18 @@ func parseOrder(value []byte) Order {
 	return Order{Id: id, Price: price, Average: tradePrice, TotalAmount: totalAmount, TradeAmount: tradeAmount, TradeMoney: tradeMoney, Symbol: currency, Status: OrderStatus(status), TradeType: TradeType(tradeType), Time: uint64(tradeDate)}
 }
 
-func getUrlToGetOrders(symbol string, tradeType TradeType, page uint64, size uint16, accessKey, secretKey string) *url.URL {
+func (c *RestClient) getUrlToGetOrders(symbol string, tradeType TradeType, page uint64, size uint16, accessKey, secretKey string) *url.URL {
 	switch tradeType {
 	case All:
-		return getOrdersIgnoreTradeType(symbol, page, size, accessKey, secretKey)
+		return c.getOrdersIgnoreTradeType(symbol, page, size, accessKey, secretKey)
 	case Buy, Sell:
-		return getOrdersNew(symbol, tradeType, page, size, accessKey, secretKey)
+		return c.getOrdersNew(symbol, tradeType, page, size, accessKey, secretKey)
 	default:
-		panic("Unknown trade type: " + string(tradeType))
+		panic("Unknown trade type: " + strconv.Itoa(int(tradeType)))
 	}
 }
 
-func getOrdersIgnoreTradeType(symbol string, page uint64, size uint16, accessKey, secretKey string) *url.URL {
+func (c *RestClient) getOrdersIgnoreTradeType(symbol string, page uint64, size uint16, accessKey, secretKey string) *url.URL {
 	q := map[string]string{
 		"currency":  symbol,
 		"pageIndex": strconv.FormatUint(page, 10),
@@ -332,12 +437,12 @@ func getOrdersIgnoreTradeType(symbol string, page uint64, size uint16, accessKey
 		"accesskey": accessKey,
 		"method":    "getOrdersIgnoreTradeType",
 	}
-	u := buildUrl(TradeApiUrl+"getOrdersIgnoreTradeType", q)
+	u := buildUrl(c.tradeApiUrl+"getOrdersIgnoreTradeType", q)
 	sign(u, secretKey)
 	return u
 }
 
-func getOrdersNew(symbol string, tradeType TradeType, page uint64, size uint16, accessKey, secretKey string) *url.URL {
+func (c *RestClient) getOrdersNew(symbol string, tradeType TradeType, page uint64, size uint16, accessKey, secretKey string) *url.URL {
 	q := map[string]string{
 		"currency":  symbol,
 		"tradeType": strconv.FormatUint(uint64(tradeType), 8),
@@ -346,11 +451,32 @@ func getOrdersNew(symbol string, tradeType TradeType, page uint64, size uint16,
 		"accesskey": accessKey,
 		"method":    "getOrdersNew",
 	}
-	u := buildUrl(TradeApiUrl+"getOrdersNew", q)
+	u := buildUrl(c.tradeApiUrl+"getOrdersNew", q)
 	sign(u, secretKey)
 	return u
 }
 
+// doTrade sends a signed request to a trade api method and returns the body once
+// it is known not to be an error.
+func (c *RestClient) doTrade(method string, q map[string]string, accessKey, secretKey string) ([]byte, error) {
+	q["accesskey"] = accessKey
+	q["method"] = method
+	u := buildUrl(c.tradeApiUrl+method, q)
+	sign(u, secretKey)
+
+	resp, err := c.doGet(u.String())
+	if err != nil {
+		return nil, err
+	}
+
+	bytes := resp.ReadBytes()
+	err = extractTradeError(bytes)
+	if err != nil {
+		return nil, err
+	}
+	return bytes, nil
+}
+
 func sign(u *url.URL, secretKey string) {
 	q := u.Query()
 	q.Set("sign", genSign(secretKey, u.Query()))
@@ -382,6 +508,9 @@ func buildQueryString(params map[string][]string) string {
 }
 
 func extractDataError(value []byte) error {
+	if !stdjson.Valid(value) {
+		return malformedError(value)
+	}
 	msg, err := json.GetString(value, "error")
 	if err == json.KeyPathNotFoundError {
 		return nil
@@ -390,14 +519,24 @@ func extractDataError(value []byte) error {
 }
 
 func extractTradeError(value []byte) error {
+	if !stdjson.Valid(value) {
+		return malformedError(value)
+	}
 	code, err := json.GetInt(value, "code")
 	if err == json.KeyPathNotFoundError || ApiCode(code) == OK {
 		return nil
 	}
-	msg, _ := json.GetString(value, "message")
+	msg, err := json.GetString(value, "message")
+	if err != nil {
+		msg, _ = json.GetString(value, "message", "des")
+	}
 	return &ApiError{Code: ApiCode(code), Message: msg}
 }
 
+func malformedError(value []byte) error {
+	return &ApiError{Code: GeneralError, Message: "Malformed response: " + string(value)}
+}
+
 type response http.Response
 
 func (r *response) ReadBytes() ([]byte) {
@@ -408,6 +547,9 @@ func (r *response) ReadBytes() ([]byte) {
 
 func (c *RestClient) doGet(url string) (*response, error) {
 	resp, err := c.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
 	r := response(*resp)
 	return &r, err
 }
@@ -0,0 +1,152 @@
+package zb_test
+
+import (
+	"github.com/berryland/zb"
+	"github.com/berryland/zb/zbtest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRestClientOffline_GetSymbols(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+
+	symbols, err := s.RestClient().GetSymbols()
+	assert.Nil(t, err)
+	assert.Equal(t, zb.SymbolConfig{AmountScale: 4, PriceScale: 2}, symbols["btc_usdt"])
+}
+
+func TestRestClientOffline_GetLatestQuote(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+
+	quote, err := s.RestClient().GetLatestQuote("btc_usdt")
+	assert.Nil(t, err)
+	assert.Equal(t, 11000.0, quote.Last)
+	assert.Equal(t, uint64(1516029900000), quote.Time)
+
+	_, err = s.RestClient().GetLatestQuote("wrong_symbol")
+	assert.NotNil(t, err)
+}
+
+func TestRestClientOffline_GetKlines(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	s.SetKlines("btc_usdt", []zb.Kline{
+		{Time: 1516029900000, Open: 1, High: 3, Low: 0.5, Close: 2, Volume: 10},
+		{Time: 1516030200000, Open: 2, High: 4, Low: 1.5, Close: 3, Volume: 20},
+	})
+
+	klines, err := s.RestClient().GetKlines("btc_usdt", "5min", 1516030000000, 20)
+	assert.Nil(t, err)
+	assert.Len(t, klines, 1)
+	assert.Equal(t, 4.0, klines[0].High)
+}
+
+func TestRestClientOffline_GetTrades(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	s.SetTrades("btc_usdt", []zb.Trade{
+		{Id: 1, TradeType: zb.Buy, Price: 11000, Amount: 0.1, Time: 1516029900},
+		{Id: 2, TradeType: zb.Sell, Price: 10999, Amount: 0.2, Time: 1516029901},
+	})
+
+	trades, err := s.RestClient().GetTrades("btc_usdt", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []zb.Trade{{Id: 2, TradeType: zb.Sell, Price: 10999, Amount: 0.2, Time: 1516029901}}, trades)
+}
+
+func TestRestClientOffline_GetDepth(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	s.SetDepth("btc_usdt", zb.Depth{
+		Asks: []zb.DepthEntry{{Price: 11001, Volume: 1}},
+		Bids: []zb.DepthEntry{{Price: 10999, Volume: 2}},
+		Time: 1516029900,
+	})
+
+	depth, err := s.RestClient().GetDepth("btc_usdt", 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 11001.0, depth.Asks[0].Price)
+	assert.Equal(t, 2.0, depth.Bids[0].Volume)
+
+	_, err = s.RestClient().GetDepth("wrong_symbol", 10)
+	assert.NotNil(t, err)
+}
+
+func TestRestClientOffline_GetAccount(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+
+	account, err := s.RestClient().GetAccount(s.AccessKey, s.SecretKey)
+	assert.Nil(t, err)
+	assert.Equal(t, "zbtest", account.Username)
+	assert.Len(t, account.Assets, 2)
+
+	_, err = s.RestClient().GetAccount(s.AccessKey, "wrong secret")
+	assert.Equal(t, zb.AuthenticationFailed, err.(*zb.ApiError).Code)
+}
+
+func TestRestClientOffline_Orders(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	c := s.RestClient()
+
+	id, err := c.PlaceOrder("btc_usdt", 15000, 0.01, zb.Sell, s.AccessKey, s.SecretKey)
+	assert.Nil(t, err)
+
+	order, err := c.GetOrder("btc_usdt", id, s.AccessKey, s.SecretKey)
+	assert.Nil(t, err)
+	assert.Equal(t, zb.Pending, order.Status)
+	assert.Equal(t, zb.Sell, order.TradeType)
+	assert.Equal(t, 0.01, order.TotalAmount)
+
+	orders, err := c.GetOrders("btc_usdt", zb.All, 1, 10, s.AccessKey, s.SecretKey)
+	assert.Nil(t, err)
+	assert.Len(t, orders, 1)
+
+	assert.Nil(t, c.CancelOrder("btc_usdt", id, s.AccessKey, s.SecretKey))
+	order, _ = c.GetOrder("btc_usdt", id, s.AccessKey, s.SecretKey)
+	assert.Equal(t, zb.Cancelled, order.Status)
+
+	err = c.CancelOrder("btc_usdt", id, s.AccessKey, s.SecretKey)
+	assert.Equal(t, zb.OrderNotFound, err.(*zb.ApiError).Code)
+}
+
+func TestRestClientOffline_InjectedErrors(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	c := s.RestClient()
+
+	s.InjectError("order", zb.TooFrequent)
+	_, err := c.PlaceOrder("btc_usdt", 15000, 0.01, zb.Sell, s.AccessKey, s.SecretKey)
+	assert.Equal(t, zb.TooFrequent, err.(*zb.ApiError).Code)
+
+	s.InjectError("getAccountInfo", zb.Maintained)
+	_, err = c.GetAccount(s.AccessKey, s.SecretKey)
+	assert.Equal(t, zb.Maintained, err.(*zb.ApiError).Code)
+
+	s.InjectMalformed("ticker")
+	_, err = c.GetLatestQuote("btc_usdt")
+	assert.NotNil(t, err)
+
+	_, err = c.GetLatestQuote("btc_usdt")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, s.Requests("ticker"))
+}
+
+func TestRestClientOffline_PlaceOrderWithClientId(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	c := s.RestClient()
+
+	id, err := c.PlaceOrderWithClientId("btc_usdt", 10000, 0.1, zb.Buy, "grid-1", zbtest.AccessKey, zbtest.SecretKey)
+	assert.Nil(t, err)
+	_, err = c.PlaceOrderWithClientId("btc_usdt", 10000, 0.1, zb.Buy, "grid-1", zbtest.AccessKey, zbtest.SecretKey)
+	assert.Equal(t, zb.InvalidArgument, err.(*zb.ApiError).Code)
+
+	order, err := c.GetOrderByClientId("btc_usdt", "grid-1", zbtest.AccessKey, zbtest.SecretKey)
+	assert.Nil(t, err)
+	assert.Equal(t, id, order.Id)
+	assert.Equal(t, zb.Buy, order.TradeType)
+}
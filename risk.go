@@ -0,0 +1,218 @@
+package zb
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+type RiskRule uint8
+
+const (
+	RiskKillSwitch RiskRule = iota
+	RiskMaxNotional
+	RiskMaxPosition
+	RiskPriceBand
+	RiskRateLimit
+	RiskCustom
+)
+
+var riskRules = []string{"kill switch", "max notional", "max position", "price band", "rate limit", "custom"}
+
+func (r RiskRule) String() string {
+	return riskRules[r]
+}
+
+// RiskError is returned by RiskGuard for orders it refuses to send.
+type RiskError struct {
+	Rule    RiskRule
+	Message string
+}
+
+func (e *RiskError) Error() string {
+	return fmt.Sprintf("Order refused by risk check (%v, %v)", e.Rule, e.Message)
+}
+
+// RiskLimits are the checks of a RiskGuard. Zero values disable a check.
+type RiskLimits struct {
+	// MaxNotional caps price times amount of one order, keyed by quote coin, e.g. "usdt"
+	MaxNotional map[string]float64
+	// MaxPosition caps the balance of a coin a buy order may lead to, keyed by coin. The
+	// balance counts frozen funds and the unfilled amount of open buy orders.
+	MaxPosition map[string]float64
+	// PriceBand is how far, as a fraction, a price may be from the last price of its market
+	PriceBand float64
+	// MaxOrdersPerSecond limits orders sent over any one second window
+	MaxOrdersPerSecond int
+}
+
+// RiskCheck is a custom check, an error refuses the order.
+type RiskCheck func(order OrderRequest) error
+
+// RiskGuard is a Trading that checks every order against RiskLimits before handing it to
+// the wrapped Trading. Refused orders return a *RiskError without contacting zb.
+// Cancels and queries always pass, so positions can be unwound while halted.
+type RiskGuard struct {
+	Trading
+	market  MarketData
+	account AccountReader
+	// buying makes buys go one at a time, so the MaxPosition check of each one sees the
+	// open orders of the ones before
+	buying sync.Mutex
+
+	mu     sync.Mutex
+	limits RiskLimits
+	checks []RiskCheck
+	halted string
+	sent   []time.Time
+}
+
+// NewRiskGuard checks prices against market and positions against account, which may be
+// nil when the respective limits are not used. A RestClient serves as all three.
+func NewRiskGuard(trading Trading, market MarketData, account AccountReader, limits RiskLimits) *RiskGuard {
+	return &RiskGuard{Trading: trading, market: market, account: account, limits: limits}
+}
+
+func (g *RiskGuard) SetLimits(limits RiskLimits) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.limits = limits
+}
+
+// AddCheck runs check after the built in ones, errors are wrapped in a RiskCustom RiskError.
+func (g *RiskGuard) AddCheck(check RiskCheck) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.checks = append(g.checks, check)
+}
+
+// Halt is the kill switch, every order is refused until Resume.
+func (g *RiskGuard) Halt(reason string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if reason == "" {
+		reason = "halted"
+	}
+	g.halted = reason
+}
+
+func (g *RiskGuard) Resume() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.halted = ""
+}
+
+func (g *RiskGuard) Halted() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.halted != ""
+}
+
+func (g *RiskGuard) PlaceOrder(symbol string, price, amount float64, tradeType TradeType, accessKey, secretKey string) (uint64, error) {
+	if tradeType == Buy {
+		g.buying.Lock()
+		defer g.buying.Unlock()
+	}
+	if err := g.Check(OrderRequest{Symbol: symbol, Price: price, Amount: amount, TradeType: tradeType}, accessKey, secretKey); err != nil {
+		return 0, err
+	}
+	return g.Trading.PlaceOrder(symbol, price, amount, tradeType, accessKey, secretKey)
+}
+
+// Check runs every check on order and, if it passes, counts it against the rate limit.
+// The kill switch and the rate limit are checked again together as it passes. Only
+// PlaceOrder keeps concurrent buys from passing MaxPosition together.
+func (g *RiskGuard) Check(order OrderRequest, accessKey, secretKey string) error {
+	g.mu.Lock()
+	limits, checks, halted := g.limits, g.checks, g.halted
+	g.mu.Unlock()
+
+	if halted != "" {
+		return &RiskError{Rule: RiskKillSwitch, Message: halted}
+	}
+	base, quote, ok := splitSymbol(order.Symbol)
+	if !ok {
+		return &ApiError{Code: InvalidArgument, Message: "Invalid symbol: " + order.Symbol}
+	}
+
+	if max, ok := limits.MaxNotional[quote]; ok && order.Price*order.Amount > max {
+		return &RiskError{Rule: RiskMaxNotional, Message: fmt.Sprintf("%v %v over %v", order.Price*order.Amount, quote, max)}
+	}
+
+	if limits.PriceBand > 0 {
+		q, err := g.market.GetLatestQuote(order.Symbol)
+		if err != nil {
+			return err
+		}
+		if q.Last <= 0 || math.Abs(order.Price-q.Last)/q.Last > limits.PriceBand {
+			return &RiskError{Rule: RiskPriceBand, Message: fmt.Sprintf("%v is more than %v away from %v", order.Price, limits.PriceBand, q.Last)}
+		}
+	}
+
+	if max, ok := limits.MaxPosition[base]; ok && order.TradeType == Buy {
+		position, err := g.position(order.Symbol, base, accessKey, secretKey)
+		if err != nil {
+			return err
+		}
+		if position+order.Amount > max {
+			return &RiskError{Rule: RiskMaxPosition, Message: fmt.Sprintf("%v %v over %v", position+order.Amount, base, max)}
+		}
+	}
+
+	for _, check := range checks {
+		if err := check(order); err != nil {
+			return &RiskError{Rule: RiskCustom, Message: err.Error()}
+		}
+	}
+
+	return g.admit()
+}
+
+func (g *RiskGuard) position(symbol string, coin string, accessKey, secretKey string) (float64, error) {
+	account, err := g.account.GetAccount(accessKey, secretKey)
+	if err != nil {
+		return 0, err
+	}
+	orders, err := g.Trading.GetOpenOrders(symbol, accessKey, secretKey)
+	if err != nil {
+		return 0, err
+	}
+
+	position := account.Totals()[strings.ToLower(coin)]
+	for _, order := range orders {
+		if order.TradeType == Buy {
+			position += order.TotalAmount - order.TradeAmount
+		}
+	}
+	return position, nil
+}
+
+// admit passes an order that passed the other checks unless the guard was halted
+// meanwhile or the rate limit is reached.
+func (g *RiskGuard) admit() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.halted != "" {
+		return &RiskError{Rule: RiskKillSwitch, Message: g.halted}
+	}
+	perSecond := g.limits.MaxOrdersPerSecond
+	if perSecond <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	kept := g.sent[:0]
+	for _, t := range g.sent {
+		if now.Sub(t) < time.Second {
+			kept = append(kept, t)
+		}
+	}
+	g.sent = kept
+	if len(g.sent) >= perSecond {
+		return &RiskError{Rule: RiskRateLimit, Message: fmt.Sprintf("more than %d orders per second", perSecond)}
+	}
+	g.sent = append(g.sent, now)
+	return nil
+}
@@ -0,0 +1,96 @@
+package zb_test
+
+import (
+	"errors"
+	"github.com/berryland/zb"
+	"github.com/berryland/zb/zbtest"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRiskGuard_PlaceOrder(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	s.SetQuote("btc_usdt", zb.Quote{Last: 10000})
+	paper := zb.NewPaperClient(map[string]float64{"usdt": 100000, "btc": 1}, 0)
+
+	g := zb.NewRiskGuard(paper, s.RestClient(), paper, zb.RiskLimits{
+		MaxNotional:        map[string]float64{"usdt": 20000},
+		MaxPosition:        map[string]float64{"btc": 2.5},
+		PriceBand:          0.05,
+		MaxOrdersPerSecond: 3,
+	})
+	rule := func(err error) zb.RiskRule {
+		if riskError, ok := err.(*zb.RiskError); ok {
+			return riskError.Rule
+		}
+		t.Fatalf("Expected a risk error, got %v", err)
+		return 0
+	}
+
+	_, err := g.PlaceOrder("btc_usdt", 10000, 2.5, zb.Buy, "", "")
+	assert.Equal(t, zb.RiskMaxNotional, rule(err))
+	_, err = g.PlaceOrder("btc_usdt", 9000, 1, zb.Buy, "", "")
+	assert.Equal(t, zb.RiskPriceBand, rule(err))
+
+	_, err = g.PlaceOrder("btc_usdt", 9900, 1, zb.Buy, "", "")
+	assert.Nil(t, err)
+	// 1 btc held and 1 more on order
+	_, err = g.PlaceOrder("btc_usdt", 9900, 1, zb.Buy, "", "")
+	assert.Equal(t, zb.RiskMaxPosition, rule(err))
+	_, err = g.PlaceOrder("btc_usdt", 10100, 0.5, zb.Sell, "", "")
+	assert.Nil(t, err)
+
+	g.AddCheck(func(order zb.OrderRequest) error {
+		if order.Amount < 0.01 {
+			return errors.New("dust")
+		}
+		return nil
+	})
+	_, err = g.PlaceOrder("btc_usdt", 10100, 0.001, zb.Sell, "", "")
+	assert.Equal(t, zb.RiskCustom, rule(err))
+
+	_, err = g.PlaceOrder("btc_usdt", 10100, 0.1, zb.Sell, "", "")
+	assert.Nil(t, err)
+	_, err = g.PlaceOrder("btc_usdt", 10100, 0.1, zb.Sell, "", "")
+	assert.Equal(t, zb.RiskRateLimit, rule(err))
+
+	g.Halt("manual stop")
+	_, err = g.PlaceOrder("btc_usdt", 10100, 0.1, zb.Sell, "", "")
+	assert.EqualError(t, err, "Order refused by risk check (kill switch, manual stop)")
+	orders, _ := g.GetOpenOrders("btc_usdt", "", "")
+	assert.Len(t, orders, 3)
+	assert.Nil(t, g.CancelOrder("btc_usdt", orders[0].Id, "", ""))
+	assert.Equal(t, 0, s.Requests("order"))
+}
+
+// slowTrading answers like a remote exchange, open orders may be outdated once they arrive.
+type slowTrading struct {
+	*zb.PaperClient
+}
+
+func (t slowTrading) GetOpenOrders(symbol string, accessKey, secretKey string) ([]zb.Order, error) {
+	orders, err := t.PaperClient.GetOpenOrders(symbol, accessKey, secretKey)
+	time.Sleep(10 * time.Millisecond)
+	return orders, err
+}
+
+func TestRiskGuard_ConcurrentBuys(t *testing.T) {
+	paper := zb.NewPaperClient(map[string]float64{"usdt": 100000, "btc": 1}, 0)
+	g := zb.NewRiskGuard(slowTrading{paper}, nil, paper, zb.RiskLimits{MaxPosition: map[string]float64{"btc": 2}})
+
+	// each buy fits on its own, only two fit together
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.PlaceOrder("btc_usdt", 9900, 0.5, zb.Buy, "", "")
+		}()
+	}
+	wg.Wait()
+	orders, _ := paper.GetOpenOrders("btc_usdt", "", "")
+	assert.Len(t, orders, 2)
+}
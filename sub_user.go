@@ -0,0 +1,145 @@
+package zb
+
+import (
+	json "github.com/buger/jsonparser"
+	"strconv"
+)
+
+type SubUser struct {
+	Id         uint64
+	Username   string
+	Memo       string
+	ApiEnabled bool
+	Frozen     bool
+}
+
+// SubUserKey is an api key pair of a sub user.
+type SubUserKey struct {
+	AccessKey string
+	SecretKey string
+}
+
+// SubUserPermissions selects what an api key created with CreateSubUserKey may do.
+type SubUserPermissions struct {
+	Assets bool
+	Orders bool
+	Lever  bool
+	Funds  bool
+}
+
+// AddSubUser creates a sub user, zb names it after the main user with the given name appended.
+func (c *RestClient) AddSubUser(name string, password string, memo string, accessKey, secretKey string) error {
+	q := map[string]string{
+		"subUserName": name,
+		"subPassword": password,
+		"memo":        memo,
+	}
+	_, err := c.doTrade("addSubUser", q, accessKey, secretKey)
+	return err
+}
+
+func (c *RestClient) GetSubUsers(accessKey, secretKey string) ([]SubUser, error) {
+	bytes, err := c.doTrade("getSubUserList", map[string]string{}, accessKey, secretKey)
+	if err != nil {
+		return []SubUser{}, err
+	}
+
+	var users []SubUser
+	json.ArrayEach(bytes, func(value []byte, dataType json.ValueType, offset int, err error) {
+		username, _ := json.GetString(value, "userName")
+		memo, _ := json.GetString(value, "memo")
+		apiEnabled, _ := json.GetBoolean(value, "isOpenApi")
+		frozen, _ := json.GetBoolean(value, "isFreez")
+		users = append(users, SubUser{Id: getUint(value, "userId"), Username: username, Memo: memo, ApiEnabled: apiEnabled, Frozen: frozen})
+	}, "message", "datas")
+	return users, nil
+}
+
+func (c *RestClient) CreateSubUserKey(subUserId uint64, name string, permissions SubUserPermissions, accessKey, secretKey string) (SubUserKey, error) {
+	q := map[string]string{
+		"toUserId":    strconv.FormatUint(subUserId, 10),
+		"keyName":     name,
+		"assetPerm":   strconv.FormatBool(permissions.Assets),
+		"entrustPerm": strconv.FormatBool(permissions.Orders),
+		"leverPerm":   strconv.FormatBool(permissions.Lever),
+		"moneyPerm":   strconv.FormatBool(permissions.Funds),
+	}
+	bytes, err := c.doTrade("createSubUserKey", q, accessKey, secretKey)
+	if err != nil {
+		return SubUserKey{}, err
+	}
+
+	apiKey, _ := json.GetString(bytes, "message", "datas", "apiKey")
+	apiSecret, _ := json.GetString(bytes, "message", "datas", "apiSecret")
+	return SubUserKey{AccessKey: apiKey, SecretKey: apiSecret}, nil
+}
+
+// TransferFunds moves amount of coin between the main user and its sub users, by user name.
+func (c *RestClient) TransferFunds(coin string, amount float64, from string, to string, accessKey, secretKey string) error {
+	q := map[string]string{
+		"currency":     coin,
+		"amount":       strconv.FormatFloat(amount, 'f', -1, 64),
+		"fromUserName": from,
+		"toUserName":   to,
+	}
+	_, err := c.doTrade("doTransferFunds", q, accessKey, secretKey)
+	return err
+}
+
+// Session binds a RestClient to one pair of credentials, e.g. those of a sub user, so
+// that signed calls no longer take them as arguments. Without those arguments it does
+// not implement Trading or AccountReader: code written against them, such as Triggers or
+// the grid and algo packages, takes the RestClient and the sub user's key pair instead.
+type Session struct {
+	client    *RestClient
+	accessKey string
+	secretKey string
+}
+
+func (c *RestClient) Session(accessKey, secretKey string) *Session {
+	return &Session{client: c, accessKey: accessKey, secretKey: secretKey}
+}
+
+func (c *RestClient) SubUserSession(key SubUserKey) *Session {
+	return c.Session(key.AccessKey, key.SecretKey)
+}
+
+func (s *Session) GetAccount() (Account, error) {
+	return s.client.GetAccount(s.accessKey, s.secretKey)
+}
+
+func (s *Session) PlaceOrder(symbol string, price, amount float64, tradeType TradeType) (uint64, error) {
+	return s.client.PlaceOrder(symbol, price, amount, tradeType, s.accessKey, s.secretKey)
+}
+
+func (s *Session) PlaceOrders(requests []OrderRequest) ([]OrderResult, error) {
+	return s.client.PlaceOrders(requests, s.accessKey, s.secretKey)
+}
+
+func (s *Session) CancelOrder(symbol string, id uint64) error {
+	return s.client.CancelOrder(symbol, id, s.accessKey, s.secretKey)
+}
+
+func (s *Session) CancelAll(symbol string) ([]CancelResult, error) {
+	return s.client.CancelAll(symbol, s.accessKey, s.secretKey)
+}
+
+func (s *Session) GetOrder(symbol string, id uint64) (Order, error) {
+	return s.client.GetOrder(symbol, id, s.accessKey, s.secretKey)
+}
+
+func (s *Session) GetOrders(symbol string, tradeType TradeType, page uint64, size uint16) ([]Order, error) {
+	return s.client.GetOrders(symbol, tradeType, page, size, s.accessKey, s.secretKey)
+}
+
+func (s *Session) GetOpenOrders(symbol string) ([]Order, error) {
+	return s.client.GetOpenOrders(symbol, s.accessKey, s.secretKey)
+}
+
+func (s *Session) GetDepositAddress(coin string) (string, error) {
+	return s.client.GetDepositAddress(coin, s.accessKey, s.secretKey)
+}
+
+func (s *Session) TransferFunds(coin string, amount float64, from string, to string) error {
+	return s.client.TransferFunds(coin, amount, from, to, s.accessKey, s.secretKey)
+}
@@ -0,0 +1,50 @@
+package zb_test
+
+import (
+	"github.com/berryland/zb"
+	"github.com/berryland/zb/zbtest"
+	"github.com/stretchr/testify/assert"
+	"net/url"
+	"testing"
+)
+
+func TestRestClientOffline_CreateSubUserKey(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	s.Respond("getSubUserList", `{"code":1000,"message":{"des":"success","isSuc":true,"datas":[
+		{"isOpenApi":false,"memo":"grid","userName":"zbtest@grid","userId":1234,"isFreez":false}
+	]}}`)
+	s.Handle("createSubUserKey", func(params url.Values) string {
+		assert.Equal(t, "1234", params.Get("toUserId"))
+		assert.Equal(t, "true", params.Get("entrustPerm"))
+		assert.Equal(t, "false", params.Get("moneyPerm"))
+		return `{"code":1000,"message":{"des":"success","isSuc":true,"datas":{"apiKey":"sub-access","apiSecret":"sub-secret"}}}`
+	})
+
+	c := s.RestClient()
+	users, err := c.GetSubUsers(s.AccessKey, s.SecretKey)
+	assert.Nil(t, err)
+	assert.Equal(t, []zb.SubUser{{Id: 1234, Username: "zbtest@grid", Memo: "grid"}}, users)
+
+	key, err := c.CreateSubUserKey(users[0].Id, "grid", zb.SubUserPermissions{Assets: true, Orders: true}, s.AccessKey, s.SecretKey)
+	assert.Nil(t, err)
+	assert.Equal(t, zb.SubUserKey{AccessKey: "sub-access", SecretKey: "sub-secret"}, key)
+}
+
+func TestSession_PlaceOrder(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	key := zb.SubUserKey{AccessKey: "sub-access", SecretKey: "sub-secret"}
+	s.AccessKey, s.SecretKey = key.AccessKey, key.SecretKey
+
+	session := s.RestClient().SubUserSession(key)
+	id, err := session.PlaceOrder("btc_usdt", 10000, 0.1, zb.Buy)
+	assert.Nil(t, err)
+
+	order, err := session.GetOrder("btc_usdt", id)
+	assert.Nil(t, err)
+	assert.Equal(t, zb.Pending, order.Status)
+
+	_, err = s.RestClient().Session(zbtest.AccessKey, zbtest.SecretKey).GetAccount()
+	assert.Equal(t, zb.AuthenticationFailed, err.(*zb.ApiError).Code)
+}
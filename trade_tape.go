@@ -0,0 +1,144 @@
+package zb
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TradeCursor persists the id of the last trade handed to a TradeCollector.
+type TradeCursor interface {
+	Load(symbol string) (uint64, error)
+	Save(symbol string, tid uint64) error
+}
+
+// FileCursor keeps one file per symbol in dir holding the last trade id.
+type FileCursor struct {
+	dir string
+}
+
+func NewFileCursor(dir string) *FileCursor {
+	return &FileCursor{dir: dir}
+}
+
+// Load returns 0 when nothing has been saved for symbol yet.
+func (c *FileCursor) Load(symbol string) (uint64, error) {
+	bytes, err := ioutil.ReadFile(c.path(symbol))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(bytes)), 10, 64)
+}
+
+// Save writes to a temporary file first, so a crash never leaves a torn cursor behind.
+func (c *FileCursor) Save(symbol string, tid uint64) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	tmp := c.path(symbol) + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strconv.FormatUint(tid, 10)+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path(symbol))
+}
+
+func (c *FileCursor) path(symbol string) string {
+	return filepath.Join(c.dir, symbol+".tid")
+}
+
+const (
+	defaultTradePollInterval = time.Second
+	tradePageInterval        = 100 * time.Millisecond
+	fullTradePage            = 50
+)
+
+// TradeCollector walks the trade tape of a symbol forward from a persisted cursor.
+type TradeCollector struct {
+	market       MarketData
+	symbol       string
+	cursor       TradeCursor
+	pollInterval time.Duration
+}
+
+func NewTradeCollector(market MarketData, symbol string, cursor TradeCursor) *TradeCollector {
+	return &TradeCollector{market: market, symbol: symbol, cursor: cursor, pollInterval: defaultTradePollInterval}
+}
+
+// SetPollInterval sets how long to wait for new trades once the collector has caught up.
+func (c *TradeCollector) SetPollInterval(interval time.Duration) {
+	c.pollInterval = interval
+}
+
+// Run hands every new batch of trades, ordered by id and without duplicates, to handler
+// and advances the cursor once handler returns nil. A batch whose handler fails is
+// handed out again after a restart. Run returns when ctx is done or on the first error.
+func (c *TradeCollector) Run(ctx context.Context, handler func(trades []Trade) error) error {
+	tid, err := c.cursor.Load(c.symbol)
+	if err != nil {
+		return err
+	}
+
+	wait := time.Duration(0)
+	for {
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		trades, err := c.market.GetTrades(c.symbol, tid)
+		if apiError, ok := err.(*ApiError); ok && apiError.Code == TooFrequent {
+			wait = c.pollInterval
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		batch := newTrades(trades, tid)
+		if len(batch) == 0 {
+			wait = c.pollInterval
+			continue
+		}
+
+		if err := handler(batch); err != nil {
+			return err
+		}
+		tid = batch[len(batch)-1].Id
+		if err := c.cursor.Save(c.symbol, tid); err != nil {
+			return err
+		}
+
+		wait = tradePageInterval
+		if len(trades) < fullTradePage {
+			wait = c.pollInterval
+		}
+	}
+}
+
+func newTrades(trades []Trade, after uint64) []Trade {
+	seen := map[uint64]bool{}
+	var batch []Trade
+	for _, t := range trades {
+		if t.Id > after && !seen[t.Id] {
+			seen[t.Id] = true
+			batch = append(batch, t)
+		}
+	}
+	sort.Slice(batch, func(i, j int) bool { return batch[i].Id < batch[j].Id })
+	return batch
+}
@@ -0,0 +1,53 @@
+package zb_test
+
+import (
+	"context"
+	"errors"
+	"github.com/berryland/zb"
+	"github.com/berryland/zb/zbtest"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTradeCollector_Run(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+	var tape []zb.Trade
+	for i := uint64(1); i <= 120; i++ {
+		tape = append(tape, zb.Trade{Id: i, TradeType: zb.Buy, Price: 11000, Amount: 0.1, Time: 1516029900 + i})
+	}
+	s.SetTrades("btc_usdt", tape)
+
+	dir, _ := ioutil.TempDir("", "zb-tape")
+	defer os.RemoveAll(dir)
+	cursor := zb.NewFileCursor(dir)
+
+	crash := errors.New("crash")
+	var collected []zb.Trade
+	collector := zb.NewTradeCollector(s.RestClient(), "btc_usdt", cursor)
+	collector.SetPollInterval(10 * time.Millisecond)
+	err := collector.Run(context.Background(), func(trades []zb.Trade) error {
+		if trades[0].Id > 50 {
+			return crash
+		}
+		collected = append(collected, trades...)
+		return nil
+	})
+	assert.Equal(t, crash, err)
+	tid, _ := cursor.Load("btc_usdt")
+	assert.Equal(t, uint64(50), tid)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err = collector.Run(ctx, func(trades []zb.Trade) error {
+		collected = append(collected, trades...)
+		if trades[len(trades)-1].Id == 120 {
+			cancel()
+		}
+		return nil
+	})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, tape, collected)
+}
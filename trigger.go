@@ -0,0 +1,465 @@
+package zb
+
+import (
+	"bufio"
+	stdjson "encoding/json"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+type TriggerKind uint8
+
+const (
+	// StopLoss fires once the price moves against the position to StopPrice
+	StopLoss TriggerKind = iota
+	// TakeProfit fires once the price moves in favor of the position to StopPrice
+	TakeProfit
+	// TrailingStop is a StopLoss whose StopPrice follows the best price seen at Trail distance
+	TrailingStop
+)
+
+type TriggerStatus uint8
+
+const (
+	TriggerPending TriggerStatus = iota
+	// TriggerFired triggers have sent their order, OrderId is 0 when it is not known whether zb got it
+	TriggerFired
+	TriggerCancelled
+	// TriggerFailed triggers fired but zb refused the order
+	TriggerFailed
+	// TriggerThrottled triggers fired but zb rate limited the order, it is sent again on
+	// the next quote or depth of the market
+	TriggerThrottled
+)
+
+const defaultTriggerSlippage = 0.005
+
+// Trigger is a conditional order kept on the client. TradeType is the side of the order
+// it fires: a Sell StopLoss protects a long position and fires when the best bid falls
+// to StopPrice, a Buy StopLoss protects a short one and fires when the best ask rises to
+// StopPrice. The order is a limit order at LimitPrice, or when that is 0 an aggressive
+// order through the best price by the slippage of the Triggers.
+type Trigger struct {
+	Id         string      `json:"id"`
+	Kind       TriggerKind `json:"kind"`
+	Symbol     string      `json:"symbol"`
+	TradeType  TradeType   `json:"trade_type"`
+	Amount     float64     `json:"amount"`
+	StopPrice  float64     `json:"stop_price"`
+	LimitPrice float64     `json:"limit_price,omitempty"`
+	// Trail is the distance of a TrailingStop from Extreme, the best price seen since it was added
+	Trail   float64 `json:"trail,omitempty"`
+	Extreme float64 `json:"extreme,omitempty"`
+	// Group holds the triggers of an OCO pair, the first one whose order zb does not refuse
+	// cancels the others
+	Group   string        `json:"group,omitempty"`
+	Status  TriggerStatus `json:"status"`
+	OrderId uint64        `json:"order_id,omitempty"`
+	// Error is why the order was refused, rate limited or may not have reached zb
+	Error string `json:"error,omitempty"`
+	Time  uint64 `json:"time"`
+}
+
+// TriggerStore is an append only file of Trigger, one json object per line. The last
+// line of a trigger id wins.
+type TriggerStore struct {
+	mu       sync.Mutex
+	file     *os.File
+	triggers map[string]Trigger
+}
+
+func OpenTriggerStore(path string) (*TriggerStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &TriggerStore{file: file, triggers: map[string]Trigger{}}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var trigger Trigger
+		// a line torn by a crash is skipped, the previous state of the trigger then holds
+		if stdjson.Unmarshal(scanner.Bytes(), &trigger) == nil && trigger.Id != "" {
+			s.triggers[trigger.Id] = trigger
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Record appends trigger and syncs the file before returning.
+func (s *TriggerStore) Record(trigger Trigger) error {
+	bytes, err := stdjson.Marshal(trigger)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(bytes, '\n')); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	s.triggers[trigger.Id] = trigger
+	return nil
+}
+
+func (s *TriggerStore) Get(id string) (Trigger, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	trigger, ok := s.triggers[id]
+	return trigger, ok
+}
+
+// Pending returns the triggers still TriggerPending, oldest first.
+func (s *TriggerStore) Pending() []Trigger {
+	return s.withStatus(TriggerPending)
+}
+
+func (s *TriggerStore) withStatus(status TriggerStatus) []Trigger {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var triggers []Trigger
+	for _, trigger := range s.triggers {
+		if trigger.Status == status {
+			triggers = append(triggers, trigger)
+		}
+	}
+	sort.Slice(triggers, func(i, j int) bool {
+		if triggers[i].Time != triggers[j].Time {
+			return triggers[i].Time < triggers[j].Time
+		}
+		return triggers[i].Id < triggers[j].Id
+	})
+	return triggers
+}
+
+func (s *TriggerStore) Close() error {
+	return s.file.Close()
+}
+
+// Triggers watches quotes or depth and fires the pending triggers of a TriggerStore.
+// Every change of a trigger is recorded before it takes effect, a trigger is marked
+// fired before its order is sent, so after a restart it never fires twice. The handler
+// is called with each trigger that fired, failed, was throttled or was cancelled by its
+// OCO sibling.
+type Triggers struct {
+	mu        sync.Mutex
+	trading   Trading
+	store     *TriggerStore
+	accessKey string
+	secretKey string
+	handler   func(trigger Trigger)
+	slippage  float64
+	symbols   map[string]SymbolConfig
+	sequence  uint64
+}
+
+// NewTriggers sends orders with trading and rounds aggressive prices to the price scales
+// of market, which may be nil. The price scales are requested once here, prices stay
+// unrounded when that fails. handler may be nil as well.
+func NewTriggers(trading Trading, market MarketData, store *TriggerStore, accessKey, secretKey string, handler func(trigger Trigger)) *Triggers {
+	var symbols map[string]SymbolConfig
+	if market != nil {
+		symbols, _ = market.GetSymbols()
+	}
+	return &Triggers{trading: trading, store: store, accessKey: accessKey, secretKey: secretKey, handler: handler, slippage: defaultTriggerSlippage, symbols: symbols}
+}
+
+// SetSlippage sets how far through the best price, as a fraction, aggressive orders are
+// priced. It defaults to 0.5%.
+func (t *Triggers) SetSlippage(rate float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.slippage = rate
+}
+
+// Add stores trigger as pending and returns its id, generated when trigger.Id is empty.
+func (t *Triggers) Add(trigger Trigger) (string, error) {
+	if trigger.TradeType != Buy && trigger.TradeType != Sell {
+		return "", &ApiError{Code: InvalidArgument, Message: "Trade type must be buy or sell"}
+	}
+	if trigger.Amount <= 0 {
+		return "", &ApiError{Code: InvalidAmount, Message: "Amount must be positive"}
+	}
+	if trigger.Kind == TrailingStop && trigger.Trail <= 0 || trigger.Kind != TrailingStop && trigger.StopPrice <= 0 {
+		return "", &ApiError{Code: InvalidPrice, Message: "Stop price and trail must be positive"}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if trigger.Id == "" {
+		trigger.Id = t.nextId()
+	}
+	if _, ok := t.store.Get(trigger.Id); ok {
+		return "", &ApiError{Code: InvalidArgument, Message: "Duplicate trigger id: " + trigger.Id}
+	}
+	trigger.Status, trigger.OrderId, trigger.Error = TriggerPending, 0, ""
+	if trigger.Time == 0 {
+		trigger.Time = nowMillis()
+	}
+	return trigger.Id, t.store.Record(trigger)
+}
+
+// OCO adds a StopLoss at stopPrice and a TakeProfit at profitPrice for the same amount,
+// either of which cancels the other when it fires.
+func (t *Triggers) OCO(symbol string, tradeType TradeType, amount float64, stopPrice, stopLimit, profitPrice, profitLimit float64) (string, string, error) {
+	t.mu.Lock()
+	group := t.nextId()
+	t.mu.Unlock()
+
+	stop, err := t.Add(Trigger{Id: group + "-stop", Kind: StopLoss, Symbol: symbol, TradeType: tradeType, Amount: amount, StopPrice: stopPrice, LimitPrice: stopLimit, Group: group})
+	if err != nil {
+		return "", "", err
+	}
+	profit, err := t.Add(Trigger{Id: group + "-profit", Kind: TakeProfit, Symbol: symbol, TradeType: tradeType, Amount: amount, StopPrice: profitPrice, LimitPrice: profitLimit, Group: group})
+	if err != nil {
+		t.Cancel(stop)
+		return "", "", err
+	}
+	return stop, profit, nil
+}
+
+// nextId must be called with t.mu held.
+func (t *Triggers) nextId() string {
+	t.sequence++
+	return strconv.FormatUint(nowMillis(), 10) + "-" + strconv.FormatUint(t.sequence, 10)
+}
+
+// Cancel cancels a pending trigger, together with the other triggers of its OCO group.
+func (t *Triggers) Cancel(id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	trigger, ok := t.store.Get(id)
+	if !ok || trigger.Status != TriggerPending {
+		return &ApiError{Code: OrderNotFound, Message: "Trigger not found"}
+	}
+	trigger.Status = TriggerCancelled
+	if err := t.store.Record(trigger); err != nil {
+		return err
+	}
+	_, err := t.cancelGroup(trigger)
+	return err
+}
+
+// cancelGroup cancels the pending siblings of trigger. t.mu must be held.
+func (t *Triggers) cancelGroup(trigger Trigger) ([]Trigger, error) {
+	if trigger.Group == "" {
+		return nil, nil
+	}
+	var cancelled []Trigger
+	for _, sibling := range t.store.Pending() {
+		if sibling.Group != trigger.Group || sibling.Id == trigger.Id {
+			continue
+		}
+		sibling.Status = TriggerCancelled
+		if err := t.store.Record(sibling); err != nil {
+			return cancelled, err
+		}
+		cancelled = append(cancelled, sibling)
+	}
+	return cancelled, nil
+}
+
+// groupFiring reports whether a trigger of group has fired and zb has not refused its
+// order yet. t.mu must be held.
+func (t *Triggers) groupFiring(group string) bool {
+	if group == "" {
+		return false
+	}
+	for _, status := range []TriggerStatus{TriggerFired, TriggerThrottled} {
+		for _, trigger := range t.store.withStatus(status) {
+			if trigger.Group == group {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// settle cancels the pending siblings of a trigger whose order zb took or may have
+// taken. Siblings of a refused or rate limited order stay pending.
+func (t *Triggers) settle(trigger Trigger) []Trigger {
+	if trigger.Status != TriggerFired {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cancelled, _ := t.cancelGroup(trigger)
+	return cancelled
+}
+
+// Pending returns the triggers that have not fired or been cancelled, oldest first.
+func (t *Triggers) Pending() []Trigger {
+	return t.store.Pending()
+}
+
+func (t *Triggers) Follow(ws Streamer, symbol string) {
+	ws.SubscribeQuote(symbol, func(quote Quote) {
+		t.OnQuote(symbol, quote)
+	})
+	ws.SubscribeDepth(symbol, func(depth Depth) {
+		t.OnDepth(symbol, depth)
+	})
+}
+
+// OnQuote checks the triggers of symbol against the best bid and ask of quote.
+func (t *Triggers) OnQuote(symbol string, quote Quote) {
+	t.evaluate(symbol, quote.Buy, quote.Sell)
+}
+
+// OnDepth checks the triggers of symbol against the best bid and ask of depth.
+func (t *Triggers) OnDepth(symbol string, depth Depth) {
+	bid, ask := 0.0, 0.0
+	for _, e := range depth.Bids {
+		bid = math.Max(bid, e.Price)
+	}
+	for _, e := range depth.Asks {
+		if ask == 0 || e.Price < ask {
+			ask = e.Price
+		}
+	}
+	t.evaluate(symbol, bid, ask)
+}
+
+// evaluate fires the triggers of symbol whose condition holds. Sell triggers watch bid,
+// the price a sell would get, and buy triggers watch ask. A price of 0 is unknown.
+func (t *Triggers) evaluate(symbol string, bid, ask float64) {
+	t.mu.Lock()
+	var retried, fired, changed []Trigger
+	for _, trigger := range t.store.withStatus(TriggerThrottled) {
+		if trigger.Symbol != symbol {
+			continue
+		}
+		// marked fired again first, so a restart does not send it twice
+		trigger.Status = TriggerFired
+		if err := t.store.Record(trigger); err != nil {
+			continue
+		}
+		retried = append(retried, trigger)
+	}
+	for _, trigger := range t.store.Pending() {
+		if trigger.Symbol != symbol {
+			continue
+		}
+		// a sibling fired earlier, this one waits for zb to take or refuse its order
+		if t.groupFiring(trigger.Group) {
+			continue
+		}
+		price := bid
+		if trigger.TradeType == Buy {
+			price = ask
+		}
+		if price <= 0 {
+			continue
+		}
+
+		if trigger.Kind == TrailingStop && t.trail(&trigger, price) {
+			if err := t.store.Record(trigger); err != nil {
+				continue
+			}
+		}
+		if !triggered(trigger, price) {
+			continue
+		}
+
+		trigger.Status = TriggerFired
+		if trigger.LimitPrice == 0 {
+			trigger.LimitPrice = t.aggressivePrice(trigger, price)
+		}
+		if err := t.store.Record(trigger); err != nil {
+			continue
+		}
+		fired = append(fired, trigger)
+	}
+	t.mu.Unlock()
+
+	for _, trigger := range retried {
+		if sent := t.fire(trigger); sent.Status != TriggerThrottled {
+			changed = append(append(changed, t.settle(sent)...), sent)
+		}
+	}
+	for _, trigger := range fired {
+		sent := t.fire(trigger)
+		changed = append(append(changed, t.settle(sent)...), sent)
+	}
+	if t.handler != nil {
+		for _, trigger := range changed {
+			t.handler(trigger)
+		}
+	}
+}
+
+// trail moves Extreme and StopPrice of a TrailingStop along with price and reports
+// whether they changed.
+func (t *Triggers) trail(trigger *Trigger, price float64) bool {
+	if trigger.Extreme != 0 && (trigger.TradeType == Sell && price <= trigger.Extreme || trigger.TradeType == Buy && price >= trigger.Extreme) {
+		return false
+	}
+	trigger.Extreme = price
+	if trigger.TradeType == Sell {
+		trigger.StopPrice = price - trigger.Trail
+	} else {
+		trigger.StopPrice = price + trigger.Trail
+	}
+	return true
+}
+
+func triggered(trigger Trigger, price float64) bool {
+	falling := trigger.TradeType == Sell
+	if trigger.Kind == TakeProfit {
+		falling = !falling
+	}
+	if falling {
+		return price <= trigger.StopPrice+epsilon
+	}
+	return price >= trigger.StopPrice-epsilon
+}
+
+// aggressivePrice prices an order slippage through price. t.mu must be held.
+func (t *Triggers) aggressivePrice(trigger Trigger, price float64) float64 {
+	if trigger.TradeType == Buy {
+		price *= 1 + t.slippage
+	} else {
+		price *= 1 - t.slippage
+	}
+
+	if config, ok := t.symbols[trigger.Symbol]; ok {
+		pow := math.Pow(10, float64(config.PriceScale))
+		if trigger.TradeType == Buy {
+			price = math.Ceil(price*pow-epsilon) / pow
+		} else {
+			price = math.Floor(price*pow+epsilon) / pow
+		}
+	}
+	return price
+}
+
+// fire sends the order of a trigger already recorded as fired.
+func (t *Triggers) fire(trigger Trigger) Trigger {
+	id, err := t.trading.PlaceOrder(trigger.Symbol, trigger.LimitPrice, trigger.Amount, trigger.TradeType, t.accessKey, t.secretKey)
+	apiError, ok := err.(*ApiError)
+	switch {
+	case err == nil:
+		trigger.OrderId, trigger.Error = id, ""
+	case ok && apiError.Code == TooFrequent:
+		trigger.Status, trigger.Error = TriggerThrottled, err.Error()
+	case ok && apiError.Code != GeneralError:
+		trigger.Status, trigger.Error = TriggerFailed, err.Error()
+	default:
+		// the order may or may not have reached zb, it stays fired without an order id
+		trigger.Error = err.Error()
+	}
+	t.store.Record(trigger)
+	return trigger
+}
@@ -0,0 +1,230 @@
+package zb
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openTriggerStore(t *testing.T, dir string) *TriggerStore {
+	store, err := OpenTriggerStore(filepath.Join(dir, "triggers"))
+	assert.Nil(t, err)
+	return store
+}
+
+// failingTrading fails the next orders with errs in turn, a nil error places the order.
+type failingTrading struct {
+	*PaperClient
+	errs []error
+}
+
+func (t *failingTrading) PlaceOrder(symbol string, price, amount float64, tradeType TradeType, accessKey, secretKey string) (uint64, error) {
+	if len(t.errs) > 0 {
+		err := t.errs[0]
+		t.errs = t.errs[1:]
+		if err != nil {
+			return 0, err
+		}
+	}
+	return t.PaperClient.PlaceOrder(symbol, price, amount, tradeType, accessKey, secretKey)
+}
+
+// symbolsMarket serves only GetSymbols and counts its calls.
+type symbolsMarket struct {
+	MarketData
+	symbols map[string]SymbolConfig
+	calls   int
+}
+
+func (m *symbolsMarket) GetSymbols() (map[string]SymbolConfig, error) {
+	m.calls++
+	return m.symbols, nil
+}
+
+func TestTriggers_PriceScale(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "triggers")
+	defer os.RemoveAll(dir)
+	store := openTriggerStore(t, dir)
+	defer store.Close()
+	paper := NewPaperClient(map[string]float64{"btc": 1}, 0)
+	market := &symbolsMarket{symbols: map[string]SymbolConfig{"btc_usdt": {AmountScale: 4, PriceScale: 2}}}
+
+	var fired []Trigger
+	triggers := NewTriggers(paper, market, store, "", "", func(trigger Trigger) {
+		fired = append(fired, trigger)
+	})
+	for i := 0; i < 2; i++ {
+		triggers.Add(Trigger{Kind: StopLoss, Symbol: "btc_usdt", TradeType: Sell, Amount: 0.1, StopPrice: 10000})
+		triggers.OnQuote("btc_usdt", Quote{Buy: 9999.99, Sell: 10000.01})
+	}
+	if assert.Len(t, fired, 2) {
+		assert.InDelta(t, 9949.99, fired[0].LimitPrice, epsilon)
+		assert.InDelta(t, 9949.99, fired[1].LimitPrice, epsilon)
+	}
+	assert.Equal(t, 1, market.calls)
+}
+
+func TestTriggers_OCO(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "triggers")
+	defer os.RemoveAll(dir)
+	paper := NewPaperClient(map[string]float64{"btc": 1}, 0)
+
+	store := openTriggerStore(t, dir)
+	var fired []Trigger
+	triggers := NewTriggers(paper, nil, store, "", "", func(trigger Trigger) {
+		fired = append(fired, trigger)
+	})
+	stop, profit, err := triggers.OCO("btc_usdt", Sell, 0.5, 10000, 9900, 12000, 0)
+	assert.Nil(t, err)
+	assert.Len(t, triggers.Pending(), 2)
+
+	triggers.OnQuote("btc_usdt", Quote{Buy: 10500, Sell: 10501})
+	assert.Empty(t, fired)
+	store.Close()
+
+	// pending triggers survive a restart
+	store = openTriggerStore(t, dir)
+	defer store.Close()
+	triggers = NewTriggers(paper, nil, store, "", "", func(trigger Trigger) {
+		fired = append(fired, trigger)
+	})
+	assert.Len(t, triggers.Pending(), 2)
+
+	triggers.OnDepth("btc_usdt", Depth{Bids: []DepthEntry{{Price: 12001, Volume: 1}, {Price: 11000, Volume: 1}}, Asks: []DepthEntry{{Price: 12002, Volume: 1}}})
+	assert.Len(t, fired, 2)
+	assert.Equal(t, stop, fired[0].Id)
+	assert.Equal(t, TriggerCancelled, fired[0].Status)
+	assert.Equal(t, profit, fired[1].Id)
+	assert.Equal(t, TriggerFired, fired[1].Status)
+	assert.InDelta(t, 12001*0.995, fired[1].LimitPrice, epsilon)
+	assert.Empty(t, triggers.Pending())
+
+	order, _ := paper.GetOrder("btc_usdt", fired[1].OrderId, "", "")
+	assert.InDelta(t, 0.5, order.TotalAmount, epsilon)
+	assert.Equal(t, Sell, order.TradeType)
+
+	triggers.OnQuote("btc_usdt", Quote{Buy: 9000, Sell: 9001})
+	assert.Len(t, fired, 2)
+}
+
+func TestTriggers_OCORefused(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "triggers")
+	defer os.RemoveAll(dir)
+	store := openTriggerStore(t, dir)
+	defer store.Close()
+	trading := &failingTrading{PaperClient: NewPaperClient(map[string]float64{"btc": 1}, 0), errs: []error{
+		&ApiError{Code: InsufficientFund, Message: "Insufficient fund"},
+	}}
+
+	var fired []Trigger
+	triggers := NewTriggers(trading, nil, store, "", "", func(trigger Trigger) {
+		fired = append(fired, trigger)
+	})
+	stop, profit, err := triggers.OCO("btc_usdt", Sell, 0.5, 10000, 9900, 12000, 12000)
+	assert.Nil(t, err)
+
+	// a refused leg leaves its sibling pending
+	triggers.OnQuote("btc_usdt", Quote{Buy: 12001, Sell: 12002})
+	if assert.Len(t, fired, 1) {
+		assert.Equal(t, profit, fired[0].Id)
+		assert.Equal(t, TriggerFailed, fired[0].Status)
+	}
+	if pending := triggers.Pending(); assert.Len(t, pending, 1) {
+		assert.Equal(t, stop, pending[0].Id)
+	}
+
+	triggers.OnQuote("btc_usdt", Quote{Buy: 9999, Sell: 10000})
+	if assert.Len(t, fired, 2) {
+		assert.Equal(t, stop, fired[1].Id)
+		assert.Equal(t, TriggerFired, fired[1].Status)
+		assert.NotEqual(t, uint64(0), fired[1].OrderId)
+	}
+	assert.Empty(t, triggers.Pending())
+}
+
+func TestTriggers_TrailingStop(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "triggers")
+	defer os.RemoveAll(dir)
+	store := openTriggerStore(t, dir)
+	defer store.Close()
+	paper := NewPaperClient(map[string]float64{"usdt": 10000}, 0)
+
+	var fired []Trigger
+	triggers := NewTriggers(paper, nil, store, "", "", func(trigger Trigger) {
+		fired = append(fired, trigger)
+	})
+	_, err := triggers.Add(Trigger{Kind: TrailingStop, Symbol: "btc_usdt", TradeType: Buy, Amount: 0.1})
+	assert.Equal(t, InvalidPrice, err.(*ApiError).Code)
+
+	id, err := triggers.Add(Trigger{Kind: TrailingStop, Symbol: "btc_usdt", TradeType: Buy, Amount: 0.1, Trail: 100, LimitPrice: 10000})
+	assert.Nil(t, err)
+
+	for _, ask := range []float64{9900, 9800, 9850, 9899} {
+		triggers.OnQuote("btc_usdt", Quote{Buy: ask - 1, Sell: ask})
+	}
+	assert.Empty(t, fired)
+	trigger, _ := store.Get(id)
+	assert.InDelta(t, 9800, trigger.Extreme, epsilon)
+	assert.InDelta(t, 9900, trigger.StopPrice, epsilon)
+
+	triggers.OnQuote("btc_usdt", Quote{Buy: 9900, Sell: 9901})
+	assert.Len(t, fired, 1)
+	assert.Equal(t, TriggerFired, fired[0].Status)
+	order, _ := paper.GetOrder("btc_usdt", fired[0].OrderId, "", "")
+	assert.InDelta(t, 10000, order.Price, epsilon)
+
+	_, err = triggers.Add(Trigger{Kind: StopLoss, Symbol: "btc_usdt", TradeType: Buy, Amount: 10, StopPrice: 10000, LimitPrice: 10000})
+	assert.Nil(t, err)
+	triggers.OnQuote("btc_usdt", Quote{Buy: 10000, Sell: 10001})
+	assert.Equal(t, TriggerFailed, fired[1].Status)
+	assert.NotEmpty(t, fired[1].Error)
+}
+
+func TestTriggers_Throttled(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "triggers")
+	defer os.RemoveAll(dir)
+	store := openTriggerStore(t, dir)
+	defer store.Close()
+	trading := &failingTrading{PaperClient: NewPaperClient(map[string]float64{"btc": 1}, 0), errs: []error{
+		&ApiError{Code: TooFrequent, Message: "Too frequent"},
+		&ApiError{Code: TooFrequent, Message: "Too frequent"},
+		nil,
+		&ApiError{Code: GeneralError, Message: "Malformed response"},
+	}}
+
+	var fired []Trigger
+	triggers := NewTriggers(trading, nil, store, "", "", func(trigger Trigger) {
+		fired = append(fired, trigger)
+	})
+	first, _ := triggers.Add(Trigger{Kind: StopLoss, Symbol: "btc_usdt", TradeType: Sell, Amount: 0.1, StopPrice: 10000, LimitPrice: 9900})
+	triggers.OnQuote("btc_usdt", Quote{Buy: 9999, Sell: 10000})
+	if assert.Len(t, fired, 1) {
+		assert.Equal(t, TriggerThrottled, fired[0].Status)
+	}
+
+	// still rate limited, nothing changed
+	triggers.OnQuote("btc_usdt", Quote{Buy: 10500, Sell: 10501})
+	assert.Len(t, fired, 1)
+
+	triggers.OnQuote("btc_usdt", Quote{Buy: 10500, Sell: 10501})
+	if assert.Len(t, fired, 2) {
+		assert.Equal(t, first, fired[1].Id)
+		assert.Equal(t, TriggerFired, fired[1].Status)
+		assert.NotEqual(t, uint64(0), fired[1].OrderId)
+		assert.Empty(t, fired[1].Error)
+	}
+
+	// an unreadable response may hide a placed order, the trigger stays fired
+	second, _ := triggers.Add(Trigger{Kind: StopLoss, Symbol: "btc_usdt", TradeType: Sell, Amount: 0.1, StopPrice: 10000, LimitPrice: 9900})
+	triggers.OnQuote("btc_usdt", Quote{Buy: 9999, Sell: 10000})
+	if assert.Len(t, fired, 3) {
+		assert.Equal(t, second, fired[2].Id)
+		assert.Equal(t, TriggerFired, fired[2].Status)
+		assert.Equal(t, uint64(0), fired[2].OrderId)
+		assert.NotEmpty(t, fired[2].Error)
+	}
+	orders, _ := trading.GetOrders("btc_usdt", All, 1, 10, "", "")
+	assert.Len(t, orders, 1)
+}
@@ -4,20 +4,26 @@ import (
 	"github.com/gorilla/websocket"
 	"log"
 	"strings"
+	"sync"
 	"github.com/buger/jsonparser"
 )
 
 const WebSocketServerUrl = "wss://api.zb.com:9999/websocket"
 
 type WebSocketClient struct {
+	url       string
 	running   bool
 	conn      *websocket.Conn
+	mu        sync.Mutex
+	recorder  *FrameWriter
+	recordErr error
 	decoders  map[string]func([]byte) interface{}
 	callbacks map[string]func(interface{})
+	closed    func(err error)
 }
 
 func NewWebSocketClient() *WebSocketClient {
-	return &WebSocketClient{running: false, decoders: make(map[string]func([]byte) interface{}), callbacks: make(map[string]func(interface{}))}
+	return &WebSocketClient{url: WebSocketServerUrl, running: false, decoders: make(map[string]func([]byte) interface{}), callbacks: make(map[string]func(interface{}))}
 }
 
 type eventMessage struct {
@@ -26,36 +32,90 @@ type eventMessage struct {
 }
 
 func (c *WebSocketClient) Connect() {
+	if err := c.TryConnect(); err != nil {
+		log.Fatalln("Fail to connect to " + c.url + ", error: " + err.Error())
+	}
+}
+
+// TryConnect is Connect for long running callers, it returns the dial error instead of exiting.
+func (c *WebSocketClient) TryConnect() error {
 	if c.running {
-		return
+		return nil
 	}
-	c.running = true
 
 	dialer := &websocket.Dialer{}
-	conn, _, err := dialer.Dial(WebSocketServerUrl, nil)
-	c.conn = conn
+	conn, _, err := dialer.Dial(c.url, nil)
 	if err != nil {
-		c.Disconnect()
-		log.Fatalln("Fail to connect to " + WebSocketServerUrl + ", error: " + err.Error())
+		return err
 	}
+	c.conn = conn
+	c.running = true
 
 	go func() {
-		defer c.Disconnect()
+		var err error
+		defer func() {
+			c.Disconnect()
+			if c.closed != nil {
+				c.closed(err)
+			}
+		}()
 		for {
-			_, bytes, err := c.conn.ReadMessage()
+			var bytes []byte
+			_, bytes, err = c.conn.ReadMessage()
 			if err != nil {
 				break
 			}
 
-			channel, _ := jsonparser.GetString(bytes, "channel")
-			if decoder, ok := c.decoders[channel]; ok {
-				value := decoder(bytes)
-				if callback, ok := c.callbacks[channel]; ok {
-					callback(value)
-				}
-			}
+			c.record(bytes)
+			c.dispatch(bytes)
 		}
 	}()
+	return nil
+}
+
+// OnClose registers a callback run once the connection is gone, whether it was
+// dropped by the server or closed by Disconnect. Subscriptions do not survive it.
+func (c *WebSocketClient) OnClose(callback func(err error)) {
+	c.closed = callback
+}
+
+// Record writes every frame received from now on to w. Recording stops at the first
+// write error, which RecordErr returns.
+func (c *WebSocketClient) Record(w *FrameWriter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.recorder, c.recordErr = w, nil
+}
+
+func (c *WebSocketClient) RecordErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.recordErr
+}
+
+func (c *WebSocketClient) record(bytes []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.recorder == nil {
+		return
+	}
+	if err := c.recorder.Write(Frame{Time: nowMillis(), Bytes: bytes}); err != nil {
+		c.recorder, c.recordErr = nil, err
+	}
+}
+
+func (c *WebSocketClient) dispatch(bytes []byte) {
+	channel, _ := jsonparser.GetString(bytes, "channel")
+	if decoder, ok := c.decoders[channel]; ok {
+		value := decoder(bytes)
+		if callback, ok := c.callbacks[channel]; ok {
+			callback(value)
+		}
+	}
+}
+
+func (c *WebSocketClient) SetServerUrl(url string) {
+	c.url = url
 }
 
 func (c *WebSocketClient) Disconnect() {
@@ -68,13 +128,45 @@ func (c *WebSocketClient) Disconnect() {
 }
 
 func (c *WebSocketClient) SubscribeQuote(symbol string, callback func(quote Quote)) {
-	channel := strings.Replace(symbol, "_", "", 1) + "_ticker"
+	channel := toChannel(symbol, "ticker")
 	c.register(channel, func(value []byte) interface{} {
 		return marshalQuote(value)
 	}, func(v interface{}) {
 		callback(v.(Quote))
 	})
-	c.conn.WriteJSON(eventMessage{Event: "addChannel", Channel: channel})
+	c.send(eventMessage{Event: "addChannel", Channel: channel})
+}
+
+func (c *WebSocketClient) SubscribeDepth(symbol string, callback func(depth Depth)) {
+	channel := toChannel(symbol, "depth")
+	c.register(channel, func(value []byte) interface{} {
+		return marshalDepth(value)
+	}, func(v interface{}) {
+		callback(v.(Depth))
+	})
+	c.send(eventMessage{Event: "addChannel", Channel: channel})
+}
+
+func (c *WebSocketClient) SubscribeTrades(symbol string, callback func(trades []Trade)) {
+	channel := toChannel(symbol, "trades")
+	c.register(channel, func(value []byte) interface{} {
+		return marshalTrades(value, "data")
+	}, func(v interface{}) {
+		callback(v.([]Trade))
+	})
+	c.send(eventMessage{Event: "addChannel", Channel: channel})
+}
+
+func toChannel(symbol string, kind string) string {
+	return strings.Replace(symbol, "_", "", 1) + "_" + kind
+}
+
+func (c *WebSocketClient) send(message eventMessage) {
+	// there is no connection when the client is driven by a Replayer
+	if c.conn == nil {
+		return
+	}
+	c.conn.WriteJSON(message)
 }
 
 func (c *WebSocketClient) register(channel string, decoder func(value []byte) interface{}, callback func(interface{})) {
@@ -0,0 +1,65 @@
+package zb_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/berryland/zb"
+	"github.com/berryland/zb/zbtest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestWebSocketClientOffline_SubscribeQuote(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+
+	c := s.WebSocketClient()
+	c.Connect()
+	defer c.Disconnect()
+
+	quotes := make(chan zb.Quote, 2)
+	c.SubscribeQuote("btc_usdt", func(quote zb.Quote) {
+		quotes <- quote
+	})
+
+	assert.Equal(t, 11000.0, (<-quotes).Last)
+
+	s.PublishQuote("btc_usdt", zb.Quote{Last: 12000, Time: 1516029960000})
+	select {
+	case quote := <-quotes:
+		assert.Equal(t, 12000.0, quote.Last)
+	case <-time.After(time.Second):
+		t.Fatal("No quote published")
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestWebSocketClientOffline_Record(t *testing.T) {
+	s := zbtest.NewServer()
+	defer s.Close()
+
+	c := s.WebSocketClient()
+	c.Connect()
+	defer c.Disconnect()
+
+	var buf bytes.Buffer
+	c.Record(zb.NewFrameWriter(&buf))
+	quotes := make(chan zb.Quote, 2)
+	c.SubscribeQuote("btc_usdt", func(quote zb.Quote) {
+		quotes <- quote
+	})
+	<-quotes
+	assert.Nil(t, c.RecordErr())
+	assert.Contains(t, buf.String(), "btcusdt_ticker")
+
+	c.Record(zb.NewFrameWriter(failingWriter{}))
+	s.PublishQuote("btc_usdt", zb.Quote{Last: 12000, Time: 1516029960000})
+	<-quotes
+	assert.EqualError(t, c.RecordErr(), "disk full")
+}
@@ -0,0 +1,753 @@
+// Package zbtest provides an in-process fake of the zb.com data, trade and websocket
+// APIs, so that code built on zb.RestClient and zb.WebSocketClient can be tested offline.
+package zbtest
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"github.com/berryland/zb"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AccessKey = "zbtest-access-key"
+	SecretKey = "zbtest-secret-key"
+)
+
+const malformedBody = `{"code":1000,"message":`
+
+type Server struct {
+	AccessKey string
+	SecretKey string
+
+	mu          sync.Mutex
+	server      *httptest.Server
+	symbols     map[string]zb.SymbolConfig
+	quotes      map[string]zb.Quote
+	klines      map[string][]zb.Kline
+	trades      map[string][]zb.Trade
+	depths      map[string]zb.Depth
+	account     zb.Account
+	orders      map[uint64]zb.Order
+	clientIds   map[string]uint64
+	nextOrderId uint64
+	scripts     map[string][]string
+	requests    map[string]int
+	handlers    map[string]func(url.Values) string
+	subscribers map[string][]*subscriber
+	upgrader    websocket.Upgrader
+}
+
+// NewServer starts a fake exchange with btc_usdt and eth_usdt markets and
+// accepts trade requests signed with AccessKey and SecretKey.
+func NewServer() *Server {
+	s := &Server{
+		AccessKey:   AccessKey,
+		SecretKey:   SecretKey,
+		symbols:     map[string]zb.SymbolConfig{},
+		quotes:      map[string]zb.Quote{},
+		klines:      map[string][]zb.Kline{},
+		trades:      map[string][]zb.Trade{},
+		depths:      map[string]zb.Depth{},
+		orders:      map[uint64]zb.Order{},
+		clientIds:   map[string]uint64{},
+		nextOrderId: 2018012200000001,
+		scripts:     map[string][]string{},
+		requests:    map[string]int{},
+		handlers:    map[string]func(url.Values) string{},
+		subscribers: map[string][]*subscriber{},
+	}
+	s.symbols["btc_usdt"] = zb.SymbolConfig{AmountScale: 4, PriceScale: 2}
+	s.symbols["eth_usdt"] = zb.SymbolConfig{AmountScale: 3, PriceScale: 2}
+	s.quotes["btc_usdt"] = zb.Quote{Volume: 1200.5, Last: 11000, Sell: 11001, Buy: 10999, High: 11500, Low: 10500, Time: 1516029900000}
+	s.quotes["eth_usdt"] = zb.Quote{Volume: 8000, Last: 1000, Sell: 1000.5, Buy: 999.5, High: 1050, Low: 950, Time: 1516029900000}
+	s.account = zb.Account{Username: "zbtest", Assets: []zb.Asset{
+		{Available: 1, Coin: zb.Coin{CnName: "BTC", EnName: "BTC", Key: "btc", Unit: "฿", Scale: 8}},
+		{Available: 10000, Coin: zb.Coin{CnName: "USDT", EnName: "USDT", Key: "usdt", Unit: "₮", Scale: 8}},
+	}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/data/v1/", s.serveData)
+	mux.HandleFunc("/api/", s.serveTrade)
+	mux.HandleFunc("/websocket", s.serveWebSocket)
+	s.server = httptest.NewServer(mux)
+	return s
+}
+
+func (s *Server) Close() {
+	s.mu.Lock()
+	for _, subscribers := range s.subscribers {
+		for _, sub := range subscribers {
+			sub.conn.Close()
+		}
+	}
+	s.subscribers = map[string][]*subscriber{}
+	s.mu.Unlock()
+	s.server.Close()
+}
+
+func (s *Server) DataApiUrl() string {
+	return s.server.URL + "/data/v1/"
+}
+
+func (s *Server) TradeApiUrl() string {
+	return s.server.URL + "/api/"
+}
+
+func (s *Server) WebSocketUrl() string {
+	return "ws" + strings.TrimPrefix(s.server.URL, "http") + "/websocket"
+}
+
+// RestClient returns a client pointed at the fake server.
+func (s *Server) RestClient() *zb.RestClient {
+	c := zb.NewRestClient()
+	c.SetBaseUrls(s.DataApiUrl(), s.TradeApiUrl())
+	return c
+}
+
+// WebSocketClient returns a client pointed at the fake server, it still has to be connected.
+func (s *Server) WebSocketClient() *zb.WebSocketClient {
+	c := zb.NewWebSocketClient()
+	c.SetServerUrl(s.WebSocketUrl())
+	return c
+}
+
+func (s *Server) SetSymbol(symbol string, config zb.SymbolConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.symbols[symbol] = config
+}
+
+func (s *Server) SetQuote(symbol string, quote zb.Quote) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quotes[symbol] = quote
+}
+
+func (s *Server) SetKlines(symbol string, klines []zb.Kline) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.klines[symbol] = klines
+}
+
+func (s *Server) SetTrades(symbol string, trades []zb.Trade) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trades[symbol] = trades
+}
+
+func (s *Server) SetDepth(symbol string, depth zb.Depth) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.depths[symbol] = depth
+}
+
+func (s *Server) SetAccount(account zb.Account) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.account = account
+}
+
+// SetOrder adds or replaces an order, e.g. to move it to a filled status.
+func (s *Server) SetOrder(order zb.Order) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.orders[order.Id] = order
+}
+
+func (s *Server) Order(id uint64) (zb.Order, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order, ok := s.orders[id]
+	return order, ok
+}
+
+// Orders returns every order known to the server, newest first.
+func (s *Server) Orders() []zb.Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sortedOrders("", zb.All)
+}
+
+// Respond queues a raw body to be returned by the next request to endpoint,
+// e.g. "ticker" or "getOrder", instead of the simulated one.
+func (s *Server) Respond(endpoint string, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[endpoint] = append(s.scripts[endpoint], body)
+}
+
+// InjectError makes the next request to endpoint fail with code.
+func (s *Server) InjectError(endpoint string, code zb.ApiCode) {
+	body := fmt.Sprintf(`{"code":%d,"message":"injected error %d"}`, code, code)
+	if isDataEndpoint(endpoint) {
+		body = fmt.Sprintf(`{"error":"injected error %d"}`, code)
+	}
+	s.Respond(endpoint, body)
+}
+
+// InjectMalformed makes the next request to endpoint return a truncated JSON body.
+func (s *Server) InjectMalformed(endpoint string) {
+	s.Respond(endpoint, malformedBody)
+}
+
+// Handle installs a simulated trade endpoint that is not built in. The handler
+// receives the verified request parameters and returns the response body.
+func (s *Server) Handle(method string, handler func(params url.Values) string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = handler
+}
+
+// Requests returns how many requests endpoint has received.
+func (s *Server) Requests(endpoint string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[endpoint]
+}
+
+// Publish pushes a raw frame to every websocket subscribed to channel.
+func (s *Server) Publish(channel string, frame []byte) {
+	s.mu.Lock()
+	subscribers := append([]*subscriber(nil), s.subscribers[channel]...)
+	s.mu.Unlock()
+
+	for _, sub := range subscribers {
+		sub.write(frame)
+	}
+}
+
+// PublishQuote updates the quote of symbol and pushes it to ticker subscribers.
+func (s *Server) PublishQuote(symbol string, quote zb.Quote) {
+	s.SetQuote(symbol, quote)
+	channel := Channel(symbol, "ticker")
+	s.Publish(channel, tickerFrame(channel, quote))
+}
+
+// PublishDepth updates the depth of symbol and pushes it to depth subscribers.
+func (s *Server) PublishDepth(symbol string, depth zb.Depth) {
+	s.SetDepth(symbol, depth)
+	channel := Channel(symbol, "depth")
+	bytes, _ := json.Marshal(map[string]interface{}{"channel": channel, "dataType": "depth", "asks": depthJson(depth.Asks, 0), "bids": depthJson(depth.Bids, 0), "timestamp": depth.Time})
+	s.Publish(channel, bytes)
+}
+
+// PublishTrades appends trades to the tape of symbol and pushes them to trades subscribers.
+func (s *Server) PublishTrades(symbol string, trades []zb.Trade) {
+	s.mu.Lock()
+	s.trades[symbol] = append(s.trades[symbol], trades...)
+	s.mu.Unlock()
+
+	data := []interface{}{}
+	for _, t := range trades {
+		data = append(data, tradeJson(t))
+	}
+	channel := Channel(symbol, "trades")
+	bytes, _ := json.Marshal(map[string]interface{}{"channel": channel, "dataType": "trades", "data": data})
+	s.Publish(channel, bytes)
+}
+
+// Subscribed reports whether any websocket has subscribed to channel.
+func (s *Server) Subscribed(channel string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subscribers[channel]) > 0
+}
+
+// WaitSubscribed waits until a websocket has subscribed to channel.
+func (s *Server) WaitSubscribed(channel string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if s.Subscribed(channel) {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return false
+}
+
+// Channel returns the websocket channel name of symbol, e.g. btcusdt_ticker.
+func Channel(symbol string, kind string) string {
+	return strings.Replace(symbol, "_", "", 1) + "_" + kind
+}
+
+func Sign(secretKey string, params url.Values) string {
+	var keys []string
+	for k := range params {
+		if k == "sign" || k == "reqTime" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var kvs []string
+	for _, k := range keys {
+		for _, v := range params[k] {
+			kvs = append(kvs, k+"="+v)
+		}
+	}
+
+	h := hmac.New(md5.New, []byte(fmt.Sprintf("%x", sha1.Sum([]byte(secretKey)))))
+	h.Write([]byte(strings.Join(kvs, "&")))
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func isDataEndpoint(endpoint string) bool {
+	switch endpoint {
+	case "markets", "ticker", "allTicker", "kline", "trades", "depth":
+		return true
+	}
+	return false
+}
+
+func (s *Server) script(endpoint string) (string, bool) {
+	s.requests[endpoint]++
+	bodies := s.scripts[endpoint]
+	if len(bodies) == 0 {
+		return "", false
+	}
+	s.scripts[endpoint] = bodies[1:]
+	return bodies[0], true
+}
+
+func (s *Server) serveData(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	endpoint := strings.TrimPrefix(r.URL.Path, "/data/v1/")
+	if body, ok := s.script(endpoint); ok {
+		writeBody(w, body)
+		return
+	}
+
+	q := r.URL.Query()
+	symbol := q.Get("market")
+	if endpoint != "markets" && endpoint != "allTicker" {
+		if _, ok := s.symbols[symbol]; !ok {
+			writeBody(w, `{"error":"market not found"}`)
+			return
+		}
+	}
+
+	switch endpoint {
+	case "markets":
+		markets := map[string]interface{}{}
+		for symbol, config := range s.symbols {
+			markets[symbol] = map[string]interface{}{"amountScale": config.AmountScale, "priceScale": config.PriceScale}
+		}
+		writeJson(w, markets)
+	case "allTicker":
+		tickers := map[string]interface{}{}
+		for symbol, quote := range s.quotes {
+			tickers[strings.Replace(symbol, "_", "", 1)] = tickerJson(quote)
+		}
+		writeJson(w, tickers)
+	case "ticker":
+		quote := s.quotes[symbol]
+		writeJson(w, map[string]interface{}{"date": strconv.FormatUint(quote.Time, 10), "ticker": tickerJson(quote)})
+	case "kline":
+		since, _ := strconv.ParseUint(q.Get("since"), 10, 64)
+		size, _ := strconv.Atoi(q.Get("size"))
+		if size <= 0 || size > 1000 {
+			size = 1000
+		}
+		data := [][]interface{}{}
+		for _, k := range s.klines[symbol] {
+			if k.Time < since || len(data) >= size {
+				continue
+			}
+			data = append(data, []interface{}{k.Time, k.Open, k.High, k.Low, k.Close, k.Volume})
+		}
+		writeJson(w, map[string]interface{}{"data": data, "symbol": symbol})
+	case "trades":
+		since, _ := strconv.ParseUint(q.Get("since"), 10, 64)
+		trades := []interface{}{}
+		for _, t := range s.trades[symbol] {
+			if t.Id <= since || len(trades) >= 50 {
+				continue
+			}
+			trades = append(trades, tradeJson(t))
+		}
+		writeJson(w, trades)
+	case "depth":
+		depth := s.depths[symbol]
+		size, _ := strconv.Atoi(q.Get("size"))
+		writeJson(w, map[string]interface{}{"asks": depthJson(depth.Asks, size), "bids": depthJson(depth.Bids, size), "timestamp": depth.Time})
+	default:
+		writeBody(w, `{"error":"unknown endpoint"}`)
+	}
+}
+
+func (s *Server) serveTrade(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	method := strings.TrimPrefix(r.URL.Path, "/api/")
+	if body, ok := s.script(method); ok {
+		writeBody(w, body)
+		return
+	}
+
+	q := r.URL.Query()
+	if q.Get("accesskey") != s.AccessKey || q.Get("method") != method || q.Get("sign") != Sign(s.SecretKey, q) {
+		writeError(w, zb.AuthenticationFailed)
+		return
+	}
+
+	if handler, ok := s.handlers[method]; ok {
+		writeBody(w, handler(q))
+		return
+	}
+
+	switch method {
+	case "getAccountInfo":
+		writeJson(w, map[string]interface{}{"result": accountJson(s.account)})
+	case "order":
+		s.placeOrder(w, q)
+	case "orderMoreV2":
+		s.placeOrders(w, q)
+	case "cancelOrder":
+		id, _ := strconv.ParseUint(q.Get("id"), 10, 64)
+		order, ok := s.orders[id]
+		if !ok || order.Symbol != q.Get("currency") || order.Status == zb.Finished || order.Status == zb.Cancelled {
+			writeError(w, zb.OrderNotFound)
+			return
+		}
+		order.Status = zb.Cancelled
+		s.orders[id] = order
+		writeError(w, zb.OK)
+	case "getOrder":
+		id, _ := strconv.ParseUint(q.Get("id"), 10, 64)
+		if clientId := q.Get("customerOrderId"); clientId != "" {
+			id = s.clientIds[clientId]
+		}
+		order, ok := s.orders[id]
+		if !ok || order.Symbol != q.Get("currency") {
+			writeError(w, zb.OrderNotFound)
+			return
+		}
+		writeJson(w, orderJson(order))
+	case "getOrdersIgnoreTradeType", "getOrdersNew":
+		tradeType := zb.All
+		if method == "getOrdersNew" {
+			t, _ := strconv.Atoi(q.Get("tradeType"))
+			tradeType = zb.TradeType(t)
+		}
+		s.writeOrderPage(w, q, s.sortedOrders(q.Get("currency"), tradeType))
+	case "getUnfinishedOrdersIgnoreTradeType":
+		var open []zb.Order
+		for _, order := range s.sortedOrders(q.Get("currency"), zb.All) {
+			if order.Status == zb.Pending || order.Status == zb.PartiallyFilled {
+				open = append(open, order)
+			}
+		}
+		s.writeOrderPage(w, q, open)
+	default:
+		writeError(w, zb.InvalidArgument)
+	}
+}
+
+func (s *Server) placeOrder(w http.ResponseWriter, q url.Values) {
+	price, err := strconv.ParseFloat(q.Get("price"), 64)
+	if err != nil {
+		writeError(w, zb.InvalidPrice)
+		return
+	}
+	amount, err := strconv.ParseFloat(q.Get("amount"), 64)
+	if err != nil {
+		writeError(w, zb.InvalidAmount)
+		return
+	}
+
+	clientId := q.Get("customerOrderId")
+	if _, ok := s.clientIds[clientId]; ok && clientId != "" {
+		writeError(w, zb.InvalidArgument)
+		return
+	}
+
+	id, code := s.newOrder(q.Get("currency"), price, amount, q.Get("tradeType"))
+	if code != zb.OK {
+		writeError(w, code)
+		return
+	}
+	if clientId != "" {
+		s.clientIds[clientId] = id
+	}
+	writeJson(w, map[string]interface{}{"code": zb.OK, "message": "success", "id": strconv.FormatUint(id, 10)})
+}
+
+func (s *Server) placeOrders(w http.ResponseWriter, q url.Values) {
+	var params [][]float64
+	if err := json.Unmarshal([]byte(q.Get("tradeParams")), &params); err != nil || len(params) == 0 {
+		writeError(w, zb.InvalidArgument)
+		return
+	}
+
+	var results []interface{}
+	for _, param := range params {
+		if len(param) != 2 {
+			results = append(results, map[string]interface{}{"code": zb.InvalidArgument})
+			continue
+		}
+		id, code := s.newOrder(q.Get("market"), param[0], param[1], q.Get("tradeType"))
+		if code != zb.OK {
+			results = append(results, map[string]interface{}{"code": code, "message": fmt.Sprintf("code %d", code)})
+			continue
+		}
+		results = append(results, map[string]interface{}{"code": zb.OK, "entrustId": strconv.FormatUint(id, 10)})
+	}
+	writeJson(w, map[string]interface{}{"code": zb.OK, "message": "success", "result": results})
+}
+
+// newOrder must be called with s.mu held.
+func (s *Server) newOrder(symbol string, price, amount float64, tradeType string) (uint64, zb.ApiCode) {
+	if _, ok := s.symbols[symbol]; !ok {
+		return 0, zb.InvalidArgument
+	}
+	if price <= 0 {
+		return 0, zb.InvalidPrice
+	}
+	if amount <= 0 {
+		return 0, zb.InvalidAmount
+	}
+	t := zb.Sell
+	if tradeType == "1" {
+		t = zb.Buy
+	}
+
+	id := s.nextOrderId
+	s.nextOrderId++
+	s.orders[id] = zb.Order{Id: id, Price: price, TotalAmount: amount, Symbol: symbol, Status: zb.Pending, TradeType: t, Time: uint64(time.Now().UnixNano() / int64(time.Millisecond))}
+	return id, zb.OK
+}
+
+func (s *Server) writeOrderPage(w http.ResponseWriter, q url.Values, orders []zb.Order) {
+	page, _ := strconv.Atoi(q.Get("pageIndex"))
+	if page < 1 {
+		page = 1
+	}
+	size, _ := strconv.Atoi(q.Get("pageSize"))
+	if size <= 0 {
+		size = 10
+	}
+
+	from := (page - 1) * size
+	if from >= len(orders) {
+		writeError(w, zb.OrderNotFound)
+		return
+	}
+	to := from + size
+	if to > len(orders) {
+		to = len(orders)
+	}
+
+	var values []interface{}
+	for _, order := range orders[from:to] {
+		values = append(values, orderJson(order))
+	}
+	writeJson(w, values)
+}
+
+// sortedOrders must be called with s.mu held.
+func (s *Server) sortedOrders(symbol string, tradeType zb.TradeType) []zb.Order {
+	var orders []zb.Order
+	for _, order := range s.orders {
+		if symbol != "" && order.Symbol != symbol {
+			continue
+		}
+		if tradeType != zb.All && order.TradeType != tradeType {
+			continue
+		}
+		orders = append(orders, order)
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].Id > orders[j].Id
+	})
+	return orders
+}
+
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	sub := &subscriber{conn: conn}
+
+	for {
+		_, bytes, err := conn.ReadMessage()
+		if err != nil {
+			s.unsubscribe(sub)
+			return
+		}
+
+		var message struct {
+			Event   string `json:"event"`
+			Channel string `json:"channel"`
+		}
+		if json.Unmarshal(bytes, &message) != nil || message.Event != "addChannel" {
+			continue
+		}
+
+		s.mu.Lock()
+		s.subscribers[message.Channel] = append(s.subscribers[message.Channel], sub)
+		var snapshot []byte
+		for symbol, quote := range s.quotes {
+			if Channel(symbol, "ticker") == message.Channel {
+				snapshot = tickerFrame(message.Channel, quote)
+			}
+		}
+		s.mu.Unlock()
+
+		if snapshot != nil {
+			sub.write(snapshot)
+		}
+	}
+}
+
+// DropConnections closes every websocket connection that has subscribed to a channel,
+// as zb does now and then, so reconnect handling can be exercised.
+func (s *Server) DropConnections() {
+	s.mu.Lock()
+	seen := map[*subscriber]bool{}
+	for _, subscribers := range s.subscribers {
+		for _, sub := range subscribers {
+			seen[sub] = true
+		}
+	}
+	s.subscribers = map[string][]*subscriber{}
+	s.mu.Unlock()
+
+	for sub := range seen {
+		sub.conn.Close()
+	}
+}
+
+func (s *Server) unsubscribe(sub *subscriber) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for channel, subscribers := range s.subscribers {
+		var kept []*subscriber
+		for _, other := range subscribers {
+			if other != sub {
+				kept = append(kept, other)
+			}
+		}
+		s.subscribers[channel] = kept
+	}
+}
+
+type subscriber struct {
+	mu   sync.Mutex
+	conn *websocket.Conn
+}
+
+func (s *subscriber) write(frame []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conn.WriteMessage(websocket.TextMessage, frame)
+}
+
+func writeBody(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(body))
+}
+
+func writeJson(w http.ResponseWriter, value interface{}) {
+	bytes, _ := json.Marshal(value)
+	writeBody(w, string(bytes))
+}
+
+func writeError(w http.ResponseWriter, code zb.ApiCode) {
+	writeJson(w, map[string]interface{}{"code": code, "message": fmt.Sprintf("code %d", code)})
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func tickerJson(quote zb.Quote) map[string]interface{} {
+	return map[string]interface{}{
+		"vol":  formatFloat(quote.Volume),
+		"last": formatFloat(quote.Last),
+		"sell": formatFloat(quote.Sell),
+		"buy":  formatFloat(quote.Buy),
+		"high": formatFloat(quote.High),
+		"low":  formatFloat(quote.Low),
+	}
+}
+
+func tickerFrame(channel string, quote zb.Quote) []byte {
+	bytes, _ := json.Marshal(map[string]interface{}{"channel": channel, "dataType": "ticker", "date": strconv.FormatUint(quote.Time, 10), "ticker": tickerJson(quote)})
+	return bytes
+}
+
+func tradeJson(t zb.Trade) map[string]interface{} {
+	tradeType := "sell"
+	if t.TradeType == zb.Buy {
+		tradeType = "buy"
+	}
+	return map[string]interface{}{"tid": t.Id, "type": tradeType, "price": formatFloat(t.Price), "amount": formatFloat(t.Amount), "date": t.Time}
+}
+
+func depthJson(entries []zb.DepthEntry, size int) [][]float64 {
+	values := [][]float64{}
+	for i, e := range entries {
+		if size > 0 && i >= size {
+			break
+		}
+		values = append(values, []float64{e.Price, e.Volume})
+	}
+	return values
+}
+
+func orderJson(order zb.Order) map[string]interface{} {
+	return map[string]interface{}{
+		"id":           strconv.FormatUint(order.Id, 10),
+		"currency":     order.Symbol,
+		"price":        order.Price,
+		"status":       order.Status,
+		"total_amount": order.TotalAmount,
+		"trade_amount": order.TradeAmount,
+		"trade_price":  order.Average,
+		"trade_money":  order.TradeMoney,
+		"trade_date":   order.Time,
+		"type":         order.TradeType,
+	}
+}
+
+func accountJson(account zb.Account) map[string]interface{} {
+	coins := []interface{}{}
+	for _, asset := range account.Assets {
+		coins = append(coins, map[string]interface{}{
+			"freez":       formatFloat(asset.Freeze),
+			"available":   formatFloat(asset.Available),
+			"cnName":      asset.Coin.CnName,
+			"enName":      asset.Coin.EnName,
+			"key":         asset.Coin.Key,
+			"unitTag":     asset.Coin.Unit,
+			"unitDecimal": asset.Coin.Scale,
+		})
+	}
+	return map[string]interface{}{
+		"coins": coins,
+		"base": map[string]interface{}{
+			"username":               account.Username,
+			"trade_password_enabled": account.TradePasswordEnabled,
+			"auth_google_enabled":    account.AuthGoogleEnabled,
+			"auth_mobile_enabled":    account.AuthMobileEnabled,
+		},
+	}
+}
@@ -0,0 +1,32 @@
+package zbtest
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func get(t *testing.T, u string) string {
+	resp, err := http.Get(u)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestServer_Handle(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.Handle("getUserAddress", func(params url.Values) string {
+		return `{"code":1000,"message":"` + params.Get("currency") + `"}`
+	})
+
+	q := url.Values{"accesskey": {AccessKey}, "currency": {"btc"}, "method": {"getUserAddress"}}
+	q.Set("sign", Sign(SecretKey, q))
+	assert.Equal(t, `{"code":1000,"message":"btc"}`, get(t, s.TradeApiUrl()+"getUserAddress?"+q.Encode()))
+
+	q.Set("currency", "eth")
+	assert.Contains(t, get(t, s.TradeApiUrl()+"getUserAddress?"+q.Encode()), `"code":1003`)
+}